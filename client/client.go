@@ -233,10 +233,8 @@ func (c Client) PlayFragment(lbryURL, sdHash, fragmentName string, w http.Respon
 		TranscodedCacheMiss.Inc()
 	}
 
-	if strings.HasSuffix(fragmentName, library.PlaylistExt) {
-		w.Header().Set(ctypeHeaderName, library.PlaylistContentType)
-	} else if strings.HasSuffix(fragmentName, library.FragmentExt) {
-		w.Header().Set(ctypeHeaderName, library.FragmentContentType)
+	if ctype := library.ContentType(fragmentName); ctype != "" {
+		w.Header().Set(ctypeHeaderName, ctype)
 	}
 
 	w.Header().Set(cacheControlHeaderName, fmt.Sprintf("public, max-age=%v", clientCacheDuration))
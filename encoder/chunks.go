@@ -0,0 +1,408 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/dispatcher"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	ffmpegt "github.com/floostack/transcoder"
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/pkg/errors"
+)
+
+const chunkDirFormat = "chunk%03d"
+
+// ErrChunkingUnsupported is returned by EncodeChunk for ladders which cannot be encoded in chunks.
+var ErrChunkingUnsupported = errors.New("chunked encoding does not support fMP4 segments")
+
+// ChunkConfig defines how long videos are split into time chunks which are encoded in parallel.
+type ChunkConfig struct {
+	// MinDuration is the video duration in seconds starting from which it is encoded in chunks.
+	MinDuration float64
+	// Duration is the target chunk duration in seconds. Chunks are cut at source keyframes,
+	// so they come out slightly longer than that.
+	Duration float64
+	// Parallel is the number of chunks encoded at the same time.
+	Parallel int
+}
+
+var DefaultChunkConfig = ChunkConfig{
+	MinDuration: 1800,
+	Duration:    300,
+	Parallel:    4,
+}
+
+// chunk is a time range of the source video, in seconds from its start.
+type chunk struct {
+	start, end float64
+}
+
+// chunkTask is a dispatcher payload for encoding a single chunk.
+type chunkTask struct {
+	ctx      context.Context
+	n        int
+	dir      string
+	args     []string
+	duration float64
+	progress chan<- chunkProgress
+	// fail is called when the chunk fails to encode.
+	fail func(n int, err error)
+	wg   *sync.WaitGroup
+}
+
+type chunkProgress struct {
+	n       int
+	seconds float64
+}
+
+type chunkWorker struct {
+	encoder encoder
+}
+
+func (c ChunkConfig) validate() error {
+	switch {
+	case c.Duration <= 0:
+		return fmt.Errorf("chunk duration must be positive, got %v", c.Duration)
+	case c.MinDuration < c.Duration:
+		return fmt.Errorf("chunked encoding minimum duration must not be less than chunk duration (%v), got %v", c.Duration, c.MinDuration)
+	case c.Parallel <= 0:
+		return fmt.Errorf("chunked encoding parallelism must be positive, got %v", c.Parallel)
+	}
+	return nil
+}
+
+// planChunks returns time ranges the input should be encoded in, or nil if it should be encoded in one piece.
+// Only videos in MPEG-TS segments are split, since fMP4 chunks would each come with their own init segment.
+func (e encoder) planChunks(ctx context.Context, input string, meta *ladder.Metadata, l ladder.Ladder, ll logging.KVLogger) []chunk {
+	if e.chunks == nil || !meta.HasVideo() {
+		return nil
+	}
+	dur, _ := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
+	if dur < e.chunks.MinDuration {
+		return nil
+	}
+	if l.FMP4() {
+		ll.Info("not encoding in chunks, fMP4 segments are not supported")
+		return nil
+	}
+	kfs, err := e.probeKeyframes(ctx, input)
+	if err != nil {
+		ll.Warn("keyframe probe failed, encoding in one piece", "err", err)
+		return nil
+	}
+	chunks := splitChunks(kfs, dur, e.chunks.Duration)
+	if len(chunks) < 2 {
+		return nil
+	}
+	return chunks
+}
+
+// probeKeyframes returns video keyframe times relative to the input start time, in ascending order.
+func (e encoder) probeKeyframes(ctx context.Context, input string) ([]float64, error) {
+	var errb bytes.Buffer
+	args := []string{
+		"-v", "error", "-select_streams", "v:0",
+		"-show_entries", "format=start_time:packet=pts_time,flags",
+		"-of", "csv=p=1", input,
+	}
+	cmd := exec.CommandContext(ctx, e.ffprobePath, args...) // #nosec G204
+	cmd.Stderr = &errb
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, lastLines(errb.String(), 5))
+	}
+	return parseKeyframes(out), nil
+}
+
+// parseKeyframes reads keyframe times from ffprobe CSV output:
+//
+//	packet,0.033000,K__
+//	packet,0.066000,___
+//	format,0.033000
+func parseKeyframes(out []byte) []float64 {
+	var start float64
+	kfs := []float64{}
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		f := strings.Split(strings.TrimSpace(sc.Text()), ",")
+		switch {
+		case len(f) == 2 && f[0] == "format":
+			start, _ = strconv.ParseFloat(f[1], 64)
+		case len(f) == 3 && f[0] == "packet" && strings.HasPrefix(f[2], "K"):
+			if t, err := strconv.ParseFloat(f[1], 64); err == nil {
+				kfs = append(kfs, t)
+			}
+		}
+	}
+	for i := range kfs {
+		kfs[i] -= start
+	}
+	sort.Float64s(kfs)
+	return kfs
+}
+
+// splitChunks cuts the video at the first keyframe after every target seconds.
+// The last chunk is merged with the previous one when it would come out shorter than half the target.
+func splitChunks(keyframes []float64, duration, target float64) []chunk {
+	chunks := []chunk{}
+	start := 0.0
+	for _, k := range keyframes {
+		if k-start < target || duration-k < target/2 {
+			continue
+		}
+		chunks = append(chunks, chunk{start, k})
+		start = k
+	}
+	return append(chunks, chunk{start, duration})
+}
+
+// alignChunk returns chunk for the time range with its boundaries moved forward to the nearest keyframe.
+// Zero end, as well as one past the last keyframe, stands for the end of the video.
+func alignChunk(keyframes []float64, start, end, duration float64) chunk {
+	next := func(t float64) float64 {
+		if t <= 0 {
+			return 0
+		}
+		for _, k := range keyframes {
+			if k >= t {
+				return k
+			}
+		}
+		return duration
+	}
+	c := chunk{next(start), duration}
+	if end > 0 {
+		c.end = next(end)
+	}
+	return c
+}
+
+// arguments returns ffmpeg arguments for encoding the chunk with ladder arguments.
+// Output timestamps are offset by the chunk start, so stitched segments continue each other seamlessly.
+func (c chunk) arguments(input string, args []string, last bool) []string {
+	a := []string{}
+	if c.start > 0 {
+		a = append(a, "-ss", formatSeconds(c.start))
+	}
+	a = append(a, "-i", input)
+	if !last {
+		a = append(a, "-t", formatSeconds(c.end-c.start))
+	}
+	a = append(a, args...)
+	if c.start > 0 {
+		a = append(a, "-output_ts_offset", formatSeconds(c.start))
+	}
+	return append(a, "v%v.m3u8")
+}
+
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 6, 64)
+}
+
+// encodeChunked encodes chunks in parallel, each into its own subdirectory of output, relaying their combined progress.
+// Once all chunks are done, their playlists are stitched together in output. If any chunk fails, the rest are stopped.
+func (e encoder) encodeChunked(ctx context.Context, input, output string, args []string, chunks []chunk, ll logging.KVLogger) *ffmpegRun {
+	progress := make(chan ffmpegt.Progress)
+	run := &ffmpegRun{Progress: progress}
+	updates := make(chan chunkProgress)
+	ctx, cancel := context.WithCancel(ctx)
+
+	var failOnce sync.Once
+	var chunkErr error
+	fail := func(n int, err error) {
+		failOnce.Do(func() {
+			chunkErr = err
+			ll.Warn("chunk encoding failed, stopping the rest", "chunk", n, "err", err)
+			cancel()
+		})
+	}
+	dirs := make([]string, len(chunks))
+	go func() {
+		d := dispatcher.Start(min(e.chunks.Parallel, len(chunks)), chunkWorker{e}, 0)
+		wg := &sync.WaitGroup{}
+		for i, c := range chunks {
+			dirs[i] = path.Join(output, fmt.Sprintf(chunkDirFormat, i))
+			wg.Add(1)
+			d.Dispatch(chunkTask{
+				ctx:      ctx,
+				n:        i,
+				dir:      dirs[i],
+				args:     c.arguments(input, args, i == len(chunks)-1),
+				duration: c.end - c.start,
+				progress: updates,
+				fail:     fail,
+				wg:       wg,
+			})
+		}
+		wg.Wait()
+		d.Stop()
+		close(updates)
+	}()
+
+	go func() {
+		defer close(progress)
+		defer cancel()
+		var total float64
+		done := make([]float64, len(chunks))
+		for _, c := range chunks {
+			total += c.end - c.start
+		}
+		for u := range updates {
+			done[u.n] = u.seconds
+			var s float64
+			for _, d := range done {
+				s += d
+			}
+			progress <- ffmpeg.Progress{Progress: s * 100 / total, CurrentTime: formatVTTTimestamp(s)}
+		}
+		if chunkErr != nil {
+			run.err = chunkErr
+			return
+		}
+		if err := stitchChunks(output, dirs); err != nil {
+			run.err = errors.Wrap(err, "could not stitch chunks")
+			return
+		}
+		ll.Info("chunks stitched", "chunks", len(chunks))
+	}()
+	return run
+}
+
+// String keeps dispatcher logs short, it would print the whole task otherwise.
+func (t chunkTask) String() string {
+	return fmt.Sprintf("chunk %d (%s)", t.n, t.dir)
+}
+
+func (w chunkWorker) Work(t dispatcher.Task) error {
+	ct := t.Payload.(chunkTask)
+	defer ct.wg.Done()
+	err := w.encoder.encodeChunk(ct)
+	if err != nil {
+		ct.fail(ct.n, err)
+	}
+	return err
+}
+
+func (e encoder) encodeChunk(t chunkTask) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(t.dir, os.ModePerm); err != nil {
+		return err
+	}
+	e.log.Debug("encoding chunk", "dir", t.dir, "args", strings.Join(t.args, " "))
+	run, err := e.startFfmpeg(t.ctx, t.dir, t.args, t.duration)
+	if err != nil {
+		return err
+	}
+	for p := range run.Progress {
+		t.progress <- chunkProgress{t.n, math.Max(0, math.Min(t.duration, p.GetProgress()*t.duration/100))}
+	}
+	return run.err
+}
+
+// stitchChunks joins media playlists of chunk encodes into a single playlist for each variant in output,
+// moving segments there and numbering them continuously. Master playlist of the first chunk is used for output.
+// Directories without playlists, left by chunks which had nothing to encode, are skipped.
+// Chunk directories are removed afterwards.
+func stitchChunks(output string, dirs []string) error {
+	var first string
+	for _, d := range dirs {
+		if _, err := os.Stat(path.Join(d, MasterPlaylist)); err == nil {
+			first = d
+			break
+		}
+	}
+	if first == "" {
+		return errors.New("no encoded chunks found")
+	}
+	entries, err := os.ReadDir(first)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		m := variantURIRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		if err := stitchPlaylist(output, dirs, m[1]); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(path.Join(first, MasterPlaylist), path.Join(output, MasterPlaylist)); err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if err := os.RemoveAll(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stitchPlaylist joins media playlists of variant number v, keeping the header of the first one
+// with target duration set to the longest of all chunks.
+func stitchPlaylist(output string, dirs []string, v string) error {
+	name := "v" + v + ".m3u8"
+	header := []string{}
+	segments := []string{}
+	target, seq := 0, 0
+	for _, dir := range dirs {
+		cont, err := os.ReadFile(path.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		first := len(header) == 0
+		for _, line := range strings.Split(string(cont), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case line == "" || line == "#EXT-X-ENDLIST":
+			case strings.HasPrefix(line, "#EXT-X-TARGETDURATION:"):
+				d, err := strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-TARGETDURATION:"))
+				if err != nil {
+					return errors.Wrapf(err, "invalid target duration in %s", path.Join(dir, name))
+				}
+				target = max(target, d)
+				if first {
+					header = append(header, line)
+				}
+			case strings.HasPrefix(line, "#EXTINF:"):
+				segments = append(segments, line)
+			case strings.HasPrefix(line, "#"):
+				if first && len(segments) == 0 {
+					header = append(header, line)
+				}
+			default:
+				seg := fmt.Sprintf("v%s_s%06d%s", v, seq, path.Ext(line))
+				if err := os.Rename(path.Join(dir, line), path.Join(output, seg)); err != nil {
+					return err
+				}
+				segments = append(segments, seg)
+				seq++
+			}
+		}
+	}
+	for i, line := range header {
+		if strings.HasPrefix(line, "#EXT-X-TARGETDURATION:") {
+			header[i] = fmt.Sprintf("#EXT-X-TARGETDURATION:%d", target)
+		}
+	}
+	lines := append(append(header, segments...), "#EXT-X-ENDLIST", "")
+	return os.WriteFile(path.Join(output, name), []byte(strings.Join(lines, "\n")), 0644) // #nosec G306
+}
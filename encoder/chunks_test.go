@@ -0,0 +1,257 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyframes(t *testing.T) {
+	out := []byte(`packet,2.033000,K__
+packet,0.066000,___
+packet,0.033000,K_
+packet,N/A,K__
+format,0.033000
+`)
+	assert.InDeltaSlice(t, []float64{0, 2}, parseKeyframes(out), 1e-9)
+	assert.Empty(t, parseKeyframes(nil))
+}
+
+func TestSplitChunks(t *testing.T) {
+	kfs := []float64{}
+	for k := 0.0; k < 100; k += 4 {
+		kfs = append(kfs, k)
+	}
+	assert.Equal(t, []chunk{{0, 32}, {32, 64}, {64, 99}}, splitChunks(kfs, 99, 30))
+	assert.Equal(t, []chunk{{0, 32}, {32, 64}, {64, 96}, {96, 112}}, splitChunks(kfs, 112, 30))
+	assert.Equal(t, []chunk{{0, 99}}, splitChunks(nil, 99, 30))
+}
+
+func TestChunkArguments(t *testing.T) {
+	args := []string{"-f", "hls"}
+	assert.Equal(t,
+		[]string{"-i", "in.mp4", "-t", "32.000000", "-f", "hls", "v%v.m3u8"},
+		chunk{0, 32}.arguments("in.mp4", args, false))
+	assert.Equal(t,
+		[]string{"-ss", "32.500000", "-i", "in.mp4", "-f", "hls", "-output_ts_offset", "32.500000", "v%v.m3u8"},
+		chunk{32.5, 64}.arguments("in.mp4", args, true))
+}
+
+func TestAlignChunk(t *testing.T) {
+	kfs := []float64{0, 4.2, 8.4, 12.6}
+	assert.Equal(t, chunk{0, 4.2}, alignChunk(kfs, 0, 4, 15))
+	assert.Equal(t, chunk{4.2, 12.6}, alignChunk(kfs, 4, 10, 15))
+	assert.Equal(t, chunk{12.6, 15}, alignChunk(kfs, 10, 0, 15))
+	assert.Equal(t, chunk{15, 15}, alignChunk(kfs, 13, 0, 15))
+	assert.Equal(t, chunk{8.4, 8.4}, alignChunk(kfs, 5, 6, 15))
+}
+
+func writeChunkPlaylist(t *testing.T, dir, name, target string, durations ...string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(dir, os.ModePerm))
+	lines := []string{"#EXTM3U", "#EXT-X-VERSION:3", "#EXT-X-TARGETDURATION:" + target, "#EXT-X-MEDIA-SEQUENCE:0", "#EXT-X-PLAYLIST-TYPE:VOD"}
+	for i, d := range durations {
+		seg := fmt.Sprintf("%s_s%06d.ts", strings.TrimSuffix(name, ".m3u8"), i)
+		lines = append(lines, "#EXTINF:"+d+",", seg)
+		require.NoError(t, os.WriteFile(path.Join(dir, seg), []byte(path.Join(dir, seg)), 0600))
+	}
+	lines = append(lines, "#EXT-X-ENDLIST", "")
+	require.NoError(t, os.WriteFile(path.Join(dir, name), []byte(strings.Join(lines, "\n")), 0600))
+}
+
+func TestStitchChunks(t *testing.T) {
+	out := t.TempDir()
+	dirs := []string{path.Join(out, "chunk000"), path.Join(out, "chunk001")}
+	writeChunkPlaylist(t, dirs[0], "v0.m3u8", "10", "10.000000", "4.500000")
+	writeChunkPlaylist(t, dirs[1], "v0.m3u8", "10", "10.000000")
+	writeChunkPlaylist(t, dirs[0], "v1.m3u8", "10", "10.000000", "4.500000")
+	writeChunkPlaylist(t, dirs[1], "v1.m3u8", "11", "10.520000")
+	require.NoError(t, os.WriteFile(path.Join(dirs[0], MasterPlaylist), []byte("#EXTM3U\n"), 0600))
+
+	require.NoError(t, stitchChunks(out, dirs))
+
+	pl, err := os.ReadFile(path.Join(out, "v0.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:10.000000,
+v0_s000000.ts
+#EXTINF:4.500000,
+v0_s000001.ts
+#EXTINF:10.000000,
+v0_s000002.ts
+#EXT-X-ENDLIST
+`, string(pl))
+	seg, err := os.ReadFile(path.Join(out, "v0_s000002.ts"))
+	require.NoError(t, err)
+	assert.Equal(t, path.Join(dirs[1], "v0_s000000.ts"), string(seg))
+
+	pl, err = os.ReadFile(path.Join(out, "v1.m3u8"))
+	require.NoError(t, err)
+	assert.Contains(t, string(pl), "#EXT-X-TARGETDURATION:11\n")
+	assert.FileExists(t, path.Join(out, "v1_s000002.ts"))
+	assert.FileExists(t, path.Join(out, MasterPlaylist))
+	assert.NoDirExists(t, dirs[0])
+	assert.NoDirExists(t, dirs[1])
+}
+
+// stubChunkScript logs ffmpeg arguments into a file and writes a single variant with two segments.
+const stubChunkScript = `echo "$@" >> %s
+printf 'frame=  100 fps=0.0 q=28.0 size=N/A time=00:00:10.00 bitrate=N/A speed=1x\r' >&2
+printf '#EXTM3U\n#EXT-X-TARGETDURATION:10\n#EXTINF:10.000000,\nv0_s000000.ts\n#EXTINF:5.000000,\nv0_s000001.ts\n#EXT-X-ENDLIST\n' > v0.m3u8
+printf '#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=2500000,RESOLUTION=1280x720\nv0.m3u8\n' > master.m3u8
+touch v0_s000000.ts v0_s000001.ts
+`
+
+// chunkedStubEncoder returns stub encoder which splits the 60 second stub video with keyframes every 2 seconds
+// into three chunks.
+func chunkedStubEncoder(t *testing.T, script string) Encoder {
+	t.Helper()
+	ffprobe := path.Join(t.TempDir(), "ffprobe")
+	require.NoError(t, os.WriteFile(ffprobe, []byte(`#!/bin/sh
+case "$*" in
+  *show_entries*)
+    for t in $(seq 0 2 58); do echo "packet,$t.000000,K__"; done
+    echo "format,0.000000" ;;
+  *)
+    cat <<'EOF'
+`+stubProbeOutput+`
+EOF
+    ;;
+esac
+`), 0700)) // #nosec G306
+	e, err := NewEncoder(stubConfig(t, script).FfprobePath(ffprobe).Chunks(&ChunkConfig{MinDuration: 30, Duration: 20, Parallel: 2}))
+	require.NoError(t, err)
+	return e
+}
+
+func waitProgress(t *testing.T, res *Result) float64 {
+	t.Helper()
+	var progress float64
+	done := make(chan struct{})
+	go func() {
+		for p := range res.Progress {
+			progress = p.GetProgress()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("progress channel not closed")
+	}
+	return progress
+}
+
+func TestEncodeChunked(t *testing.T) {
+	argsLog := path.Join(t.TempDir(), "args")
+	e := chunkedStubEncoder(t, fmt.Sprintf(stubChunkScript, argsLog))
+
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+	res, err := e.Encode(in, out)
+	require.NoError(t, err)
+
+	progress := waitProgress(t, res)
+	require.NoError(t, res.Err())
+	assert.InDelta(t, 50, progress, 1e-9)
+
+	pl, err := os.ReadFile(path.Join(out, "v0.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, 6, strings.Count(string(pl), "#EXTINF:"))
+	for i := 0; i < 6; i++ {
+		assert.FileExists(t, path.Join(out, fmt.Sprintf("v0_s%06d.ts", i)))
+	}
+	assert.FileExists(t, path.Join(out, MasterPlaylist))
+	assert.NoDirExists(t, path.Join(out, "chunk000"))
+
+	args, err := os.ReadFile(argsLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-i "+in+" -t 20.000000 ")
+	assert.Contains(t, string(args), "-ss 20.000000 -i "+in+" -t 20.000000 ")
+	assert.Contains(t, string(args), "-ss 40.000000 -i "+in+" -")
+	assert.Contains(t, string(args), "-output_ts_offset 40.000000 v%v.m3u8")
+}
+
+func TestEncodeChunkedFailure(t *testing.T) {
+	e := chunkedStubEncoder(t, `case "$*" in
+  *"-ss 20.000000"*)
+    echo "in.mp4: Invalid data found when processing input" >&2
+    exit 1 ;;
+esac
+exec sleep 30
+`)
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+	res, err := e.Encode(in, out)
+	require.NoError(t, err)
+
+	waitProgress(t, res)
+	var fe *FfmpegError
+	require.ErrorAs(t, res.Err(), &fe)
+	assert.Equal(t, ErrorCorruptInput, fe.Kind)
+	assert.NoDirExists(t, out)
+}
+
+func TestEncodeChunkAssemble(t *testing.T) {
+	argsLog := path.Join(t.TempDir(), "args")
+	e := chunkedStubEncoder(t, fmt.Sprintf(stubChunkScript, argsLog))
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+
+	dirs := []string{path.Join(t.TempDir(), "0"), path.Join(t.TempDir(), "1"), path.Join(t.TempDir(), "2")}
+	ranges := [][2]float64{{0, 29}, {29, 59}, {59, 0}}
+	for i, r := range ranges {
+		res, err := e.EncodeChunk(context.Background(), in, dirs[i], r[0], r[1])
+		require.NoError(t, err)
+		waitProgress(t, res)
+		require.NoError(t, res.Err())
+	}
+	assert.NoFileExists(t, path.Join(dirs[2], "v0.m3u8"), "no keyframes after 59s, nothing should be encoded")
+	args, err := os.ReadFile(argsLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-i "+in+" -t 30.000000 ")
+	assert.Contains(t, string(args), "-ss 30.000000 -i "+in+" -")
+	assert.NotContains(t, string(args), "-ss 30.000000 -i "+in+" -t ")
+	assert.Contains(t, string(args), "-output_ts_offset 30.000000 v%v.m3u8")
+
+	out := path.Join(t.TempDir(), "out")
+	res, err := e.Assemble(context.Background(), in, out, dirs)
+	require.NoError(t, err)
+	waitProgress(t, res)
+	require.NoError(t, res.Err())
+	pl, err := os.ReadFile(path.Join(out, "v0.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(pl), "#EXTINF:"))
+	assert.FileExists(t, path.Join(out, "v0_s000003.ts"))
+	assert.FileExists(t, path.Join(out, MasterPlaylist))
+	for _, d := range dirs {
+		assert.NoDirExists(t, d)
+	}
+}
+
+func TestEncodeChunkFMP4(t *testing.T) {
+	l := ladder.Default
+	l.SegmentType = ladder.SegmentTypeFMP4
+	e := chunkedStubEncoder(t, "").WithLadder(l)
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+
+	_, err := e.EncodeChunk(context.Background(), in, out, 0, 30)
+	assert.ErrorIs(t, err, ErrChunkingUnsupported)
+}
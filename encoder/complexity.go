@@ -0,0 +1,76 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	probeSamples        = 4
+	probeSampleDuration = 4.0
+)
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
+// probeComplexity makes fast constant-quality test encodes of several segments sampled across the video
+// and evaluates their average bitrate against the ladder reference.
+func (e encoder) probeComplexity(ctx context.Context, input string, meta *ladder.Metadata, probe ladder.ComplexityProbe) (*ladder.Complexity, error) {
+	dur, err := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
+	if err != nil || dur <= 0 {
+		return nil, errors.New("cannot determine video duration")
+	}
+
+	var totalBytes byteCounter
+	var totalDur float64
+	for _, ss := range sampleOffsets(dur, probeSamples, probeSampleDuration) {
+		t := min(probeSampleDuration, dur-ss)
+		var errb bytes.Buffer
+		args := []string{
+			"-hide_banner", "-nostdin", "-loglevel", "error",
+			"-ss", strconv.FormatFloat(ss, 'f', 3, 64), "-t", strconv.FormatFloat(t, 'f', 3, 64),
+			"-i", input,
+			"-map", "v:0", "-an", "-sn",
+			"-vf", "scale=-2:" + strconv.Itoa(ladder.ProbeHeight),
+			"-c:v", string(ladder.CodecH264), "-preset", "veryfast", "-crf", strconv.Itoa(ladder.ProbeCRF),
+			"-f", "h264", "-",
+		}
+		cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+		cmd.Stdout = &totalBytes
+		cmd.Stderr = &errb
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("sample encode at %.1fs failed: %w (%s)", ss, err, errb.String())
+		}
+		totalDur += t
+	}
+	if totalBytes == 0 || totalDur == 0 {
+		return nil, errors.New("sample encodes produced no output")
+	}
+
+	return probe.Evaluate(int(float64(totalBytes) * 8 / totalDur)), nil
+}
+
+// sampleOffsets returns starting points for n samples of duration d spread evenly across the video.
+// Short videos are sampled once from the beginning.
+func sampleOffsets(duration float64, n int, d float64) []float64 {
+	if duration <= float64(n)*d {
+		return []float64{0}
+	}
+	offsets := make([]float64, n)
+	step := duration / float64(n+1)
+	for i := range offsets {
+		offsets[i] = step*float64(i+1) - d/2
+	}
+	return offsets
+}
@@ -0,0 +1,12 @@
+package encoder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleOffsets(t *testing.T) {
+	assert.Equal(t, []float64{0}, sampleOffsets(12, 4, 4))
+	assert.Equal(t, []float64{18, 38, 58, 78}, sampleOffsets(100, 4, 4))
+}
@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging/zapadapter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubProbeOutput = `{
+  "format": {"duration": "60.0", "bit_rate": "2000000"},
+  "streams": [
+    {"index": 0, "codec_type": "video", "width": 1280, "height": 720, "bit_rate": "2000000",
+     "avg_frame_rate": "30/1", "r_frame_rate": "30/1", "pix_fmt": "yuv420p"}
+  ]
+}`
+
+// stubFfmpegBlocking imitates a long encode, blocking until killed.
+const stubFfmpegBlocking = `touch v0.m3u8
+exec sleep 30
+`
+
+// stubEncoder returns encoder which runs shell scripts in place of ffprobe and ffmpeg.
+// The ffmpeg stub runs script for every call other than the availability check.
+func stubEncoder(t *testing.T, script string) Encoder {
+	t.Helper()
+	e, err := NewEncoder(stubConfig(t, script))
+	require.NoError(t, err)
+	return e
+}
+
+func stubConfig(t *testing.T, script string) *Configuration {
+	t.Helper()
+	dir := t.TempDir()
+	ffprobe := path.Join(dir, "ffprobe")
+	ffmpeg := path.Join(dir, "ffmpeg")
+	require.NoError(t, os.WriteFile(ffprobe, []byte("#!/bin/sh\ncat <<'EOF'\n"+stubProbeOutput+"\nEOF\n"), 0700)) // #nosec G306
+	require.NoError(t, os.WriteFile(ffmpeg, []byte(`#!/bin/sh
+case "$1" in
+  -h|-v) exit 0 ;;
+esac
+`+script), 0700)) // #nosec G306
+
+	return Configure().
+		FfmpegPath(ffmpeg).FfprobePath(ffprobe).
+		Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).
+		Sprites(nil).Poster(nil)
+}
+
+func TestEncodeContextCancel(t *testing.T) {
+	e := stubEncoder(t, stubFfmpegBlocking)
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	res, err := e.EncodeContext(ctx, in, out)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path.Join(out, "v0.m3u8"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond, "ffmpeg stub did not start")
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range res.Progress {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("progress channel not closed after cancellation")
+	}
+	assert.ErrorIs(t, res.Err(), context.Canceled)
+	assert.NoDirExists(t, out)
+}
+
+func TestEncodeContextCancelled(t *testing.T) {
+	e := stubEncoder(t, stubFfmpegBlocking)
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := e.EncodeContext(ctx, in, out)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoDirExists(t, out)
+}
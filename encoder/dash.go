@@ -0,0 +1,258 @@
+package encoder
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+
+	"github.com/grafov/m3u8"
+	"github.com/pkg/errors"
+)
+
+const (
+	DASHManifest = "manifest.mpd"
+
+	dashTimescale = 1000
+	dashRoleURI   = "urn:mpeg:dash:role:2011"
+)
+
+type mpd struct {
+	XMLName                   xml.Name  `xml:"MPD"`
+	XMLNS                     string    `xml:"xmlns,attr"`
+	Profiles                  string    `xml:"profiles,attr"`
+	Type                      string    `xml:"type,attr"`
+	MediaPresentationDuration string    `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string    `xml:"minBufferTime,attr"`
+	Period                    mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	ID               int                 `xml:"id,attr"`
+	ContentType      string              `xml:"contentType,attr"`
+	MimeType         string              `xml:"mimeType,attr"`
+	Lang             string              `xml:"lang,attr,omitempty"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	Role             *mpdDescriptor      `xml:"Role,omitempty"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdDescriptor struct {
+	SchemeIDURI string `xml:"schemeIdUri,attr"`
+	Value       string `xml:"value,attr"`
+}
+
+type mpdRepresentation struct {
+	ID                string         `xml:"id,attr"`
+	Bandwidth         int            `xml:"bandwidth,attr"`
+	Codecs            string         `xml:"codecs,attr"`
+	Width             int            `xml:"width,attr,omitempty"`
+	Height            int            `xml:"height,attr,omitempty"`
+	FrameRate         string         `xml:"frameRate,attr,omitempty"`
+	AudioSamplingRate string         `xml:"audioSamplingRate,attr,omitempty"`
+	SegmentList       mpdSegmentList `xml:"SegmentList"`
+}
+
+type mpdSegmentList struct {
+	Timescale      int           `xml:"timescale,attr"`
+	Initialization mpdURL        `xml:"Initialization"`
+	Timeline       []mpdTimeline `xml:"SegmentTimeline>S"`
+	SegmentURLs    []mpdSegment  `xml:"SegmentURL"`
+}
+
+type mpdURL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+}
+
+type mpdSegment struct {
+	Media string `xml:"media,attr"`
+}
+
+type mpdTimeline struct {
+	T *int `xml:"t,attr"`
+	D int  `xml:"d,attr"`
+	R int  `xml:"r,attr,omitempty"`
+}
+
+// writeDASHManifest writes MPEG-DASH manifest into dir, referencing the fMP4 segments listed in HLS media playlists.
+// Video tiers are grouped into adaptation sets by codec, each audio rendition gets an adaptation set of its own.
+// Subtitles are not included since their segments are not fMP4.
+func writeDASHManifest(dir string, l ladder.Ladder) error {
+	if !l.FMP4() {
+		return errors.New("DASH manifest requires fMP4 segments")
+	}
+	// Audio muxed into video variants has no segments of its own to reference.
+	if l.Metadata != nil && l.Metadata.HasAudio && !l.IsAudioOnly() && !l.SharedAudio() {
+		return errors.New("DASH manifest requires shared audio")
+	}
+	m := mpd{
+		XMLNS:         "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:      "urn:mpeg:dash:profile:isoff-main:2011",
+		Type:          "static",
+		MinBufferTime: fmt.Sprintf("PT%.0fS", l.SegmentDuration()),
+		Period:        mpdPeriod{ID: "0"},
+	}
+
+	var duration float64
+	sets := map[string]int{}
+	for n, t := range l.Tiers {
+		rep, dur, err := dashRepresentation(dir, n)
+		if err != nil {
+			return err
+		}
+		duration = math.Max(duration, dur)
+		rep.Bandwidth = t.VideoBitrate
+		rep.Codecs = l.VideoCodecTag(n)
+		rep.Width, rep.Height = t.Width, t.Height
+		switch {
+		case !t.Framerate.IsZero() && !t.KeepFramerate:
+			rep.FrameRate = t.Framerate.String()
+		case l.Metadata != nil && l.Metadata.FPS != nil:
+			rep.FrameRate = l.Metadata.FPS.String()
+		}
+
+		key := fmt.Sprintf("%s/%v", t.Codec, t.HDR)
+		i, ok := sets[key]
+		if !ok {
+			i = len(m.Period.AdaptationSets)
+			sets[key] = i
+			m.Period.AdaptationSets = append(m.Period.AdaptationSets, mpdAdaptationSet{
+				ID: i, ContentType: "video", MimeType: "video/mp4", SegmentAlignment: true,
+			})
+		}
+		m.Period.AdaptationSets[i].Representations = append(m.Period.AdaptationSets[i].Representations, rep)
+		// Remuxed tiers are cut at source keyframes, so their segments don't line up with encoded ones.
+		if t.Copy {
+			m.Period.AdaptationSets[i].SegmentAlignment = false
+		}
+	}
+
+	sampleRate := l.Args["ar"]
+	if sampleRate == "" {
+		sampleRate = "44100"
+	}
+	audio := []mpdAdaptationSet{}
+	switch {
+	case l.IsAudioOnly():
+		set := mpdAdaptationSet{ContentType: "audio", MimeType: "audio/mp4", SegmentAlignment: true}
+		for n, br := range l.Audio.Tiers {
+			rep, dur, err := dashRepresentation(dir, n)
+			if err != nil {
+				return err
+			}
+			duration = math.Max(duration, dur)
+			rep.Bandwidth = parseBitrate(br)
+			set.Representations = append(set.Representations, dashAudio(rep, sampleRate))
+		}
+		audio = append(audio, set)
+	case l.SharedAudio():
+		def := l.DefaultAudioTrack()
+		for i, t := range l.AudioTracks() {
+			rep, dur, err := dashRepresentation(dir, len(l.Tiers)+i)
+			if err != nil {
+				return err
+			}
+			duration = math.Max(duration, dur)
+			rep.Bandwidth = parseBitrate(l.Audio.Bitrate)
+			role := "alternate"
+			switch {
+			case i == def:
+				role = "main"
+			case t.Commentary:
+				role = "commentary"
+			}
+			audio = append(audio, mpdAdaptationSet{
+				ContentType: "audio", MimeType: "audio/mp4", Lang: t.Language, SegmentAlignment: true,
+				Role:            &mpdDescriptor{SchemeIDURI: dashRoleURI, Value: role},
+				Representations: []mpdRepresentation{dashAudio(rep, sampleRate)},
+			})
+		}
+	}
+	for _, set := range audio {
+		set.ID = len(m.Period.AdaptationSets)
+		m.Period.AdaptationSets = append(m.Period.AdaptationSets, set)
+	}
+	m.MediaPresentationDuration = fmt.Sprintf("PT%.3fS", duration)
+
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append([]byte(xml.Header), out...)
+	return os.WriteFile(path.Join(dir, DASHManifest), append(out, '\n'), 0644) // #nosec G306
+}
+
+// dashRepresentation reads HLS media playlist of variant n and makes a representation with its segments.
+// Total duration of the segments is returned along with it.
+func dashRepresentation(dir string, n int) (mpdRepresentation, float64, error) {
+	rep := mpdRepresentation{ID: strconv.Itoa(n)}
+	name := fmt.Sprintf("v%d.m3u8", n)
+	f, err := os.Open(path.Join(dir, name))
+	if err != nil {
+		return rep, 0, err
+	}
+	defer f.Close()
+	p, _, err := m3u8.DecodeFrom(f, true)
+	if err != nil {
+		return rep, 0, errors.Wrapf(err, "cannot parse %s", name)
+	}
+	pl, ok := p.(*m3u8.MediaPlaylist)
+	if !ok {
+		return rep, 0, fmt.Errorf("%s is not a media playlist", name)
+	}
+	if pl.Map == nil {
+		return rep, 0, fmt.Errorf("%s has no initialization segment", name)
+	}
+
+	sl := mpdSegmentList{Timescale: dashTimescale, Initialization: mpdURL{SourceURL: pl.Map.URI}}
+	var total float64
+	var start int
+	for _, seg := range pl.Segments {
+		if seg == nil {
+			continue
+		}
+		d := int(math.Round(seg.Duration * dashTimescale))
+		if last := len(sl.Timeline) - 1; last >= 0 && sl.Timeline[last].D == d {
+			sl.Timeline[last].R++
+		} else {
+			s := mpdTimeline{D: d}
+			if last < 0 {
+				s.T = &start
+			}
+			sl.Timeline = append(sl.Timeline, s)
+		}
+		sl.SegmentURLs = append(sl.SegmentURLs, mpdSegment{Media: seg.URI})
+		total += seg.Duration
+	}
+	rep.SegmentList = sl
+	return rep, total, nil
+}
+
+func dashAudio(rep mpdRepresentation, sampleRate string) mpdRepresentation {
+	rep.Codecs = ladder.AudioCodecTag
+	rep.AudioSamplingRate = sampleRate
+	return rep
+}
+
+// parseBitrate converts ffmpeg bitrate notation (128k, 1.5M) into bits per second.
+func parseBitrate(br string) int {
+	mul := 1.0
+	switch {
+	case strings.HasSuffix(br, "k"), strings.HasSuffix(br, "K"):
+		mul = 1000
+	case strings.HasSuffix(br, "M"):
+		mul = 1000000
+	}
+	v, _ := strconv.ParseFloat(strings.TrimRight(br, "kKM"), 64)
+	return int(v * mul)
+}
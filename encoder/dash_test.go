@@ -0,0 +1,131 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/floostack/transcoder/ffmpeg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFMP4Playlist(t *testing.T, dir string, n int, durations ...float64) {
+	t.Helper()
+	pl := fmt.Sprintf("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-MAP:URI=\"v%d_init.mp4\"\n", n)
+	for i, d := range durations {
+		pl += fmt.Sprintf("#EXTINF:%.6f,\nv%d_s%06d.m4s\n", d, n, i)
+	}
+	pl += "#EXT-X-ENDLIST\n"
+	require.NoError(t, os.WriteFile(path.Join(dir, fmt.Sprintf("v%d.m3u8", n)), []byte(pl), 0600))
+}
+
+func TestWriteDASHManifest(t *testing.T) {
+	dir := t.TempDir()
+	writeFMP4Playlist(t, dir, 0, 6, 6, 6, 2.5)
+	writeFMP4Playlist(t, dir, 1, 6, 6, 6, 2.5)
+	writeFMP4Playlist(t, dir, 2, 6, 6, 6, 2.5)
+	writeFMP4Playlist(t, dir, 3, 6, 6, 6, 2.5)
+	writeFMP4Playlist(t, dir, 4, 6, 6, 6, 2.5)
+
+	l := ladder.Ladder{
+		Args: map[string]string{"hls_time": "6", "ar": "48000"},
+		Metadata: &ladder.Metadata{
+			VideoStream: ffmpeg.Streams{CodecType: "video"},
+			HasAudio:    true,
+			FPS:         &ladder.FPS{Ratio: "30/1", Float: 30},
+			AudioTracks: []ladder.AudioTrack{
+				{Index: 1, Language: "en", Default: true},
+				{Index: 2, Language: "en", Commentary: true},
+			},
+		},
+		Tiers: []ladder.Tier{
+			{Width: 1920, Height: 1080, VideoBitrate: 3500000},
+			{Width: 1280, Height: 720, VideoBitrate: 2000000},
+			{Width: 1920, Height: 1080, VideoBitrate: 2500000, Codec: ladder.CodecHEVC},
+		},
+		Audio: ladder.Audio{Bitrate: "128k"},
+		DASH:  true,
+	}
+	require.True(t, l.SharedAudio())
+	require.NoError(t, writeDASHManifest(dir, l))
+
+	cont, err := os.ReadFile(path.Join(dir, DASHManifest))
+	require.NoError(t, err)
+	mpd := string(cont)
+
+	assert.Contains(t, mpd, `<?xml version="1.0" encoding="UTF-8"?>`)
+	assert.Contains(t, mpd, `type="static" mediaPresentationDuration="PT20.500S" minBufferTime="PT6S"`)
+	assert.Contains(t, mpd, `<AdaptationSet id="0" contentType="video" mimeType="video/mp4" segmentAlignment="true">`)
+	assert.Contains(t, mpd, `<AdaptationSet id="1" contentType="video" mimeType="video/mp4" segmentAlignment="true">`)
+	assert.Contains(t, mpd,
+		`<Representation id="1" bandwidth="2000000" codecs="avc1.4D401F" width="1280" height="720" frameRate="30/1">`)
+	assert.Contains(t, mpd, `<Representation id="2" bandwidth="2500000" codecs="hvc1.1.6.L120.B0"`)
+	assert.Contains(t, mpd, `<Initialization sourceURL="v0_init.mp4"></Initialization>`)
+	assert.Contains(t, mpd, `<S t="0" d="6000" r="2"></S>`)
+	assert.Contains(t, mpd, `<S d="2500"></S>`)
+	assert.Contains(t, mpd, `<SegmentURL media="v2_s000003.m4s"></SegmentURL>`)
+	assert.Contains(t, mpd, `<AdaptationSet id="2" contentType="audio" mimeType="audio/mp4" lang="en" segmentAlignment="true">`)
+	assert.Contains(t, mpd, `<Role schemeIdUri="urn:mpeg:dash:role:2011" value="main"></Role>`)
+	assert.Contains(t, mpd, `<Role schemeIdUri="urn:mpeg:dash:role:2011" value="commentary"></Role>`)
+	assert.Contains(t, mpd, `<Representation id="4" bandwidth="128000" codecs="mp4a.40.2" audioSamplingRate="48000">`)
+	assert.NotContains(t, mpd, `id="5"`)
+}
+
+func TestWriteDASHManifestCopyTier(t *testing.T) {
+	dir := t.TempDir()
+	writeFMP4Playlist(t, dir, 0, 5, 7.5, 4, 4)
+	writeFMP4Playlist(t, dir, 1, 6, 6, 6, 2.5)
+	writeFMP4Playlist(t, dir, 2, 6, 6, 6, 2.5)
+
+	l := ladder.Ladder{
+		Args: map[string]string{"hls_time": "6"},
+		Metadata: &ladder.Metadata{
+			VideoStream: ffmpeg.Streams{CodecType: "video", CodecName: "h264", Profile: "Main", Level: 40},
+			FPS:         &ladder.FPS{Ratio: "30/1", Float: 30},
+		},
+		Tiers: []ladder.Tier{
+			{Width: 1920, Height: 1080, VideoBitrate: 3500000, Copy: true},
+			{Width: 1280, Height: 720, VideoBitrate: 2000000},
+			{Width: 1920, Height: 1080, VideoBitrate: 2500000, Codec: ladder.CodecHEVC},
+		},
+		DASH: true,
+	}
+	require.NoError(t, writeDASHManifest(dir, l))
+
+	cont, err := os.ReadFile(path.Join(dir, DASHManifest))
+	require.NoError(t, err)
+	mpd := string(cont)
+	assert.Contains(t, mpd, `<AdaptationSet id="0" contentType="video" mimeType="video/mp4" segmentAlignment="false">`)
+	assert.Contains(t, mpd, `<AdaptationSet id="1" contentType="video" mimeType="video/mp4" segmentAlignment="true">`)
+	assert.Contains(t, mpd, `<S t="0" d="5000"></S>`)
+}
+
+func TestWriteDASHManifestErrors(t *testing.T) {
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{VideoStream: ffmpeg.Streams{CodecType: "video"}, HasAudio: true},
+		Tiers:    []ladder.Tier{{Width: 1280, Height: 720}},
+	}
+	assert.ErrorContains(t, writeDASHManifest(t.TempDir(), l), "requires fMP4")
+
+	l.SegmentType = ladder.SegmentTypeFMP4
+	assert.ErrorContains(t, writeDASHManifest(t.TempDir(), l), "requires shared audio")
+
+	l.DASH = true
+	assert.Error(t, writeDASHManifest(t.TempDir(), l), "missing media playlists")
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(dir, "v0.m3u8"), []byte("#EXTM3U\n#EXTINF:6.0,\nv0_s000000.ts\n#EXT-X-ENDLIST\n"), 0600))
+	assert.ErrorContains(t, writeDASHManifest(dir, l), "no initialization segment")
+}
+
+func TestParseBitrate(t *testing.T) {
+	assert.Equal(t, 128000, parseBitrate("128k"))
+	assert.Equal(t, 1500000, parseBitrate("1.5M"))
+	assert.Equal(t, 96000, parseBitrate("96000"))
+	assert.Equal(t, 0, parseBitrate(""))
+}
@@ -2,6 +2,7 @@ package encoder
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -13,10 +14,10 @@ import (
 	"github.com/OdyseeTeam/transcoder/internal/metrics"
 	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/pkg/logging"
+	"github.com/OdyseeTeam/transcoder/pkg/mp4box"
 
 	ffmpegt "github.com/floostack/transcoder"
 	"github.com/floostack/transcoder/ffmpeg"
-	"github.com/karrick/godirwalk"
 	"github.com/pkg/errors"
 )
 
@@ -24,20 +25,35 @@ const MasterPlaylist = "master.m3u8"
 
 type Encoder interface {
 	Encode(in, out string) (*Result, error)
+	// EncodeContext works like Encode but stops ffmpeg and removes the output directory once ctx is cancelled.
+	EncodeContext(ctx context.Context, in, out string) (*Result, error)
+	// EncodeChunk encodes a time range of the video, chunks of the whole video are joined together by Assemble.
+	EncodeChunk(ctx context.Context, in, out string, start, end float64) (*Result, error)
+	// Assemble makes the output out of chunks encoded by EncodeChunk instead of encoding the video.
+	Assemble(ctx context.Context, in, out string, chunkDirs []string) (*Result, error)
 	GetMetadata(input string) (*ladder.Metadata, error)
+	// WithLadder returns a copy of the encoder which uses the provided ladder instead of the configured one.
+	WithLadder(l ladder.Ladder) Encoder
 }
 
 type Configuration struct {
-	ffmpegPath, ffprobePath,
-	spritegenPath string
-
-	ladder ladder.Ladder
-	log    logging.KVLogger
+	ffmpegPath, ffprobePath string
+
+	ladder  ladder.Ladder
+	sprites *SpriteConfig
+	poster  *PosterConfig
+	preview *PreviewConfig
+	chunks  *ChunkConfig
+	quality *QualityConfig
+	policy  *InputPolicy
+	log     logging.KVLogger
 }
 
 type encoder struct {
 	*Configuration
 	spriteGen *SpriteGenerator
+	// vmaf is set when ffmpeg supports VMAF scoring.
+	vmaf bool
 }
 
 type Result struct {
@@ -45,6 +61,16 @@ type Result struct {
 	OrigMeta      *ladder.Metadata
 	Ladder        ladder.Ladder
 	Progress      <-chan ffmpegt.Progress
+	// Quality contains rendition scores if quality measurement is configured. It is only valid once Progress is closed.
+	Quality []Quality
+
+	err error
+}
+
+// Err returns context error if the encode was cancelled or *FfmpegError if ffmpeg failed.
+// It is only valid once Progress is closed.
+func (r *Result) Err() error {
+	return r.err
 }
 
 // Configure will attempt to lookup paths to ffmpeg and ffprobe.
@@ -52,14 +78,18 @@ type Result struct {
 func Configure() *Configuration {
 	ffmpegPath, _ := exec.LookPath("ffmpeg")
 	ffprobePath, _ := exec.LookPath("ffprobe")
-	spritegenPath, _ := exec.LookPath("node")
+	sprites := DefaultSpriteConfig
+	poster := DefaultPosterConfig
+	policy := DefaultInputPolicy
 
 	return &Configuration{
-		ffmpegPath:    ffmpegPath,
-		ffprobePath:   ffprobePath,
-		spritegenPath: spritegenPath,
-		ladder:        ladder.Default,
-		log:           logging.NoopKVLogger{},
+		ffmpegPath:  ffmpegPath,
+		ffprobePath: ffprobePath,
+		ladder:      ladder.Default,
+		sprites:     &sprites,
+		poster:      &poster,
+		policy:      &policy,
+		log:         logging.NoopKVLogger{},
 	}
 }
 
@@ -87,14 +117,45 @@ func NewEncoder(cfg *Configuration) (Encoder, error) {
 
 	e := encoder{Configuration: cfg}
 
-	spriteGen, err := NewSpriteGenerator(e.spritegenPath, e.log)
-	if err != nil {
-		e.log.Info("sprite generator was not configured", "err", err)
-	} else {
+	if cfg.sprites != nil {
+		spriteGen, err := NewSpriteGenerator(cfg.ffmpegPath, *cfg.sprites, cfg.log)
+		if err != nil {
+			return nil, errors.Wrap(err, "sprite generator configuration error")
+		}
 		e.spriteGen = spriteGen
 	}
+	if cfg.poster != nil && (cfg.poster.Width <= 0 || cfg.poster.Width%2 != 0) {
+		return nil, fmt.Errorf("poster width must be positive and even, got %v", cfg.poster.Width)
+	}
+	if cfg.preview != nil {
+		if err := cfg.preview.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.chunks != nil {
+		if err := cfg.chunks.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.policy != nil {
+		if err := cfg.policy.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.quality != nil {
+		if err := cfg.quality.validate(); err != nil {
+			return nil, err
+		}
+		if cfg.quality.VMAF {
+			e.vmaf = hasVMAF(cfg.ffmpegPath)
+			if !e.vmaf {
+				cfg.log.Warn("ffmpeg is built without libvmaf, VMAF scores will not be measured")
+			}
+		}
+	}
 
-	e.log.Info("encoder configured", "ffmpeg", e.ffmpegPath, "ffprobe", e.ffprobePath, "spritegen", e.spritegenPath)
+	e.log.Info("encoder configured", "ffmpeg", e.ffmpegPath, "ffprobe", e.ffprobePath,
+		"sprites", e.sprites != nil, "poster", e.poster != nil, "preview", e.preview != nil, "chunks", e.chunks != nil, "quality", e.quality != nil, "vmaf", e.vmaf, "policy", e.policy != nil)
 	return &e, nil
 }
 
@@ -108,8 +169,41 @@ func (c *Configuration) FfprobePath(p string) *Configuration {
 	return c
 }
 
-func (c *Configuration) SpritegenPath(p string) *Configuration {
-	c.spritegenPath = p
+// Sprites configures thumbnail sprite sheets generation, nil disables it.
+// DefaultSpriteConfig is used unless configured otherwise.
+func (c *Configuration) Sprites(s *SpriteConfig) *Configuration {
+	c.sprites = s
+	return c
+}
+
+// Poster configures poster frame extraction, nil disables it. DefaultPosterConfig is used unless configured otherwise.
+func (c *Configuration) Poster(p *PosterConfig) *Configuration {
+	c.poster = p
+	return c
+}
+
+// Preview configures animated preview clip, nil (the default) disables it.
+func (c *Configuration) Preview(p *PreviewConfig) *Configuration {
+	c.preview = p
+	return c
+}
+
+// Chunks configures encoding long videos in time chunks in parallel, nil (the default) disables it.
+func (c *Configuration) Chunks(ch *ChunkConfig) *Configuration {
+	c.chunks = ch
+	return c
+}
+
+// Quality configures measuring quality of renditions against the source after encoding, nil (the default) disables it.
+func (c *Configuration) Quality(q *QualityConfig) *Configuration {
+	c.quality = q
+	return c
+}
+
+// InputPolicy configures limits inputs are checked against before encoding, nil disables the check.
+// DefaultInputPolicy is used unless configured otherwise.
+func (c *Configuration) InputPolicy(p *InputPolicy) *Configuration {
+	c.policy = p
 	return c
 }
 
@@ -125,13 +219,86 @@ func (c *Configuration) Ladder(l ladder.Ladder) *Configuration {
 	return c
 }
 
+func (e encoder) WithLadder(l ladder.Ladder) Encoder {
+	cfg := *e.Configuration
+	cfg.ladder = l
+	e.Configuration = &cfg
+	return &e
+}
+
 // Encode does transcoding of specified video file into a series of HLS streams.
 func (e encoder) Encode(input, output string) (*Result, error) {
+	return e.EncodeContext(context.Background(), input, output)
+}
+
+// EncodeContext does transcoding of specified video file into a series of HLS streams.
+// Cancelling ctx kills ffmpeg and removes the output directory, Result.Err reports that once Progress is closed,
+// as well as ffmpeg failures.
+func (e encoder) EncodeContext(ctx context.Context, input, output string) (*Result, error) {
+	return e.encode(ctx, input, output, e.startEncode)
+}
+
+// EncodeChunk encodes the part of the video between start and end seconds (zero end standing for the end of the video)
+// into HLS streams with timestamps continuing from the part start. Both boundaries are moved forward
+// to the nearest keyframe, so chunks encoded separately for adjacent ranges join seamlessly in Assemble.
+// Only media playlists and segments are produced, the rest of the output is made by Assemble.
+func (e encoder) EncodeChunk(ctx context.Context, input, output string, start, end float64) (*Result, error) {
+	ll := e.log.With("input", input, "output", output, "start", start, "end", end)
+	res, err := e.prepare(ctx, input, output, ll)
+	if err != nil {
+		return nil, err
+	}
+	if res.Ladder.FMP4() {
+		return nil, e.cleanup(ctx, output, ErrChunkingUnsupported)
+	}
+	kfs, err := e.probeKeyframes(ctx, input)
+	if err != nil {
+		return nil, e.cleanup(ctx, output, errors.Wrap(err, "keyframe probe failed"))
+	}
+	dur, _ := strconv.ParseFloat(res.OrigMeta.FMeta.GetFormat().GetDuration(), 64)
+	c := alignChunk(kfs, start, end, dur)
+
+	var run *ffmpegRun
+	if c.end > c.start {
+		args := c.arguments(input, res.Ladder.ArgumentSet(output).GetStrArguments(), c.end >= dur)
+		ll.Info("encoding chunk", "args", strings.Join(args, " "))
+		run, err = e.startFfmpeg(ctx, output, args, c.end-c.start)
+		if err != nil {
+			return nil, e.cleanup(ctx, output, err)
+		}
+	} else {
+		ll.Info("no keyframes in chunk range, nothing to encode")
+		run = finishedRun(nil)
+	}
+	res.Progress = e.finalize(ctx, run, res, ll, false)
+	return res, nil
+}
+
+// Assemble makes the same output as Encode would, stitching chunks made by EncodeChunk from chunkDirs
+// instead of encoding the video. Chunk directories are removed afterwards.
+func (e encoder) Assemble(ctx context.Context, input, output string, chunkDirs []string) (*Result, error) {
+	return e.encode(ctx, input, output, func(context.Context, *Result, []string, logging.KVLogger) (*ffmpegRun, error) {
+		if err := stitchChunks(output, chunkDirs); err != nil {
+			return finishedRun(errors.Wrap(err, "could not stitch chunks")), nil
+		}
+		return finishedRun(nil), nil
+	})
+}
+
+// startFunc starts encoding HLS streams for the prepared result with ladder arguments.
+type startFunc func(ctx context.Context, res *Result, args []string, ll logging.KVLogger) (*ffmpegRun, error)
+
+// prepare probes the input, creates output directory and builds the ladder the input should be encoded with.
+func (e encoder) prepare(ctx context.Context, input, output string, ll logging.KVLogger) (*Result, error) {
 	meta, err := e.GetMetadata(input)
 	if err != nil {
 		return nil, err
 	}
-	ll := e.log.With("input", input, "output", output)
+	if e.policy != nil {
+		if err := e.policy.Check(meta); err != nil {
+			return nil, err
+		}
+	}
 
 	fi, err := os.Stat(input)
 	if os.IsNotExist(err) {
@@ -148,65 +315,170 @@ func (e encoder) Encode(input, output string) (*Result, error) {
 		return nil, err
 	}
 
+	if e.ladder.ComplexityProbe.Enabled && meta.HasVideo() {
+		c, err := e.probeComplexity(ctx, input, meta, e.ladder.ComplexityProbe)
+		if err != nil {
+			ll.Warn("complexity probe failed", "err", err)
+		} else {
+			ll.Info("complexity probe done", "sample_bitrate", c.SampleBitrate, "factor", c.Factor)
+			meta.Complexity = c
+		}
+	}
+
 	targetLadder, err := e.ladder.Tweak(meta)
 	if err != nil {
 		return nil, err
 	}
-	res := &Result{Input: input, Output: output, OrigMeta: meta, Ladder: targetLadder}
+	e.confirmRemux(ctx, input, &targetLadder, ll)
+	return &Result{Input: input, Output: output, OrigMeta: meta, Ladder: targetLadder}, nil
+}
 
-	if e.spriteGen != nil {
-		err := e.spriteGen.Generate(input, output)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not start spritegen")
-		}
-		os.RemoveAll(path.Join(output, "processing"))
-		outputFiles, err := godirwalk.ReadDirnames(output, nil)
+// encode makes sprites and stills for the input, starts encoding it with start and post-processes the output.
+func (e encoder) encode(ctx context.Context, input, output string, start startFunc) (*Result, error) {
+	ll := e.log.With("input", input, "output", output)
+	res, err := e.prepare(ctx, input, output, ll)
+	if err != nil {
+		return nil, err
+	}
+	meta := res.OrigMeta
+
+	if e.spriteGen != nil && meta.HasVideo() {
+		err := e.spriteGen.Generate(ctx, input, output, meta)
 		if err != nil {
-			return nil, errors.Wrap(err, "could not get file list")
-		}
-		if len(outputFiles) == 0 {
-			ll.Warn("spritegen produced no files")
-		} else {
-			ll.Info("spritegen done", "files_count", len(outputFiles))
+			return nil, e.cleanup(ctx, output, errors.Wrap(err, "could not generate sprites"))
 		}
 	}
+	if meta.HasVideo() {
+		e.generateStills(ctx, input, output, meta, ll)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, e.cleanup(ctx, output, err)
+	}
 
-	args := targetLadder.ArgumentSet(output)
-	vs := meta.VideoStream
-	ll.Info(
-		"starting transcoding",
-		"args", strings.Join(args.GetStrArguments(), " "),
+	args := res.Ladder.ArgumentSet(output).GetStrArguments()
+	logFields := []any{
+		"args", strings.Join(args, " "),
 		"duration", meta.FMeta.GetFormat().GetDuration(),
 		"bitrate", meta.FMeta.GetFormat().GetBitRate(),
-		"framerate", fmt.Sprintf("%.4f[%s]", meta.FPS.Float, meta.FPS.String()),
-		"width", vs.GetWidth(),
-		"height", vs.GetHeight(),
-	)
+		"faststart", meta.FastStart,
+		"fragmented", meta.Fragmented,
+	}
+	heightLabel := "audio"
+	if meta.HasVideo() {
+		w, h := meta.DisplaySize()
+		logFields = append(logFields,
+			"framerate", fmt.Sprintf("%.4f[%s]", meta.FPS.Float, meta.FPS.String()),
+			"vfr", meta.VFR,
+			"width", w,
+			"height", h,
+			"rotation", meta.Rotation,
+		)
+		heightLabel = fmt.Sprintf("%v", h)
+	}
+	ll.Info("starting transcoding", logFields...)
 
 	dur, _ := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
 	btr, _ := strconv.ParseFloat(meta.FMeta.GetFormat().GetBitRate(), 64)
 	metrics.EncodedDurationSeconds.Add(dur)
-	metrics.EncodedBitrateMbit.WithLabelValues(fmt.Sprintf("%v", vs.GetHeight())).Observe(btr / 1024 / 1024)
-
-	progress, err := ffmpeg.New(
-		&ffmpeg.Config{
-			FfmpegBinPath:   e.ffmpegPath,
-			FfprobeBinPath:  e.ffprobePath,
-			ProgressEnabled: true,
-			Verbose:         false, // Set this to true if ffmpeg is failing to launch to see the reason
-			OutputDir:       output,
-		}).
-		Input(input).
-		Output("v%v.m3u8").
-		Start(args)
+	metrics.EncodedBitrateMbit.WithLabelValues(heightLabel).Observe(btr / 1024 / 1024)
+
+	run, err := start(ctx, res, args, ll)
 	if err != nil {
-		return nil, err
+		return nil, e.cleanup(ctx, output, err)
 	}
-
-	res.Progress = progress
+	res.Progress = e.finalize(ctx, run, res, ll, true)
 	return res, nil
 }
 
+// startEncode starts ffmpeg encoding the whole input, or parallel chunk encodes if the input is long enough.
+func (e encoder) startEncode(ctx context.Context, res *Result, args []string, ll logging.KVLogger) (*ffmpegRun, error) {
+	if chunks := e.planChunks(ctx, res.Input, res.OrigMeta, res.Ladder, ll); chunks != nil {
+		ll.Info("encoding in chunks", "chunks", len(chunks), "parallel", e.chunks.Parallel)
+		return e.encodeChunked(ctx, res.Input, res.Output, args, chunks, ll), nil
+	}
+	dur, _ := strconv.ParseFloat(res.OrigMeta.FMeta.GetFormat().GetDuration(), 64)
+	return e.startFfmpeg(ctx, res.Output, append(append([]string{"-i", res.Input}, args...), "v%v.m3u8"), dur)
+}
+
+// cleanup removes output directory if ctx was cancelled, returning context error in place of err then.
+func (e encoder) cleanup(ctx context.Context, output string, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	os.RemoveAll(output)
+	return ctx.Err()
+}
+
+// finalize relays encoding progress and post-processes the output once ffmpeg is done, if post is set.
+// The returned channel is closed only after post-processing is complete.
+// Output of cancelled and failed encodes is removed instead of being post-processed.
+func (e encoder) finalize(ctx context.Context, run *ffmpegRun, res *Result, ll logging.KVLogger, post bool) <-chan ffmpegt.Progress {
+	out := make(chan ffmpegt.Progress)
+	go func() {
+		defer close(out)
+		for p := range run.Progress {
+			out <- p
+		}
+		defer func() {
+			if err := e.cleanup(ctx, res.Output, nil); err != nil {
+				res.err = err
+				ll.Info("encoding cancelled, output removed", "err", err)
+			}
+		}()
+		if ctx.Err() != nil {
+			return
+		}
+		if run.err != nil {
+			res.err = run.err
+			os.RemoveAll(res.Output)
+			fields := []any{"err", run.err}
+			var fe *FfmpegError
+			if errors.As(run.err, &fe) {
+				fields = append(fields, "kind", fe.Kind, "stderr", strings.Join(fe.Stderr, "\n"))
+			}
+			ll.Error("encoding failed, output removed", fields...)
+			return
+		}
+		if !post {
+			return
+		}
+		subs := e.extractSubtitles(ctx, res, ll)
+		if err := fixMasterPlaylist(res.Output, res.Ladder, subs); err != nil {
+			ll.Warn("could not fix master playlist", "err", err)
+		}
+		if e.quality != nil && res.OrigMeta.HasVideo() {
+			e.scoreQuality(ctx, res, ll)
+		}
+		if res.Ladder.DASH {
+			if err := writeDASHManifest(res.Output, res.Ladder); err != nil {
+				ll.Warn("could not write DASH manifest", "err", err)
+			}
+		}
+		if res.Ladder.Progressive != nil {
+			if err := e.encodeProgressive(ctx, res, ll); err != nil {
+				ll.Warn("could not encode progressive MP4", "err", err)
+			}
+		}
+	}()
+	return out
+}
+
+// encodeProgressive encodes progressive MP4 rendition in a separate ffmpeg run once HLS output is done.
+func (e encoder) encodeProgressive(ctx context.Context, res *Result, ll logging.KVLogger) error {
+	var errb bytes.Buffer
+	args := append(
+		[]string{"-hide_banner", "-nostdin", "-loglevel", "error", "-i", res.Input},
+		res.Ladder.ProgressiveArguments(path.Join(res.Output, ladder.ProgressiveName))...)
+	ll.Info("encoding progressive MP4", "args", strings.Join(args, " "))
+	cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		os.Remove(path.Join(res.Output, ladder.ProgressiveName))
+		return fmt.Errorf("%w (%s)", err, errb.String())
+	}
+	return nil
+}
+
 // getMetadata uses ffprobe to parse video file metadata.
 func (e encoder) GetMetadata(input string) (*ladder.Metadata, error) {
 	meta := &ffmpeg.Metadata{}
@@ -234,35 +506,45 @@ func (e encoder) GetMetadata(input string) (*ladder.Metadata, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to wrap with ladder.Metadata")
 	}
-	lm.FastStart, err = e.checkFastStart(input)
-	if err != nil {
-		return nil, errors.Wrap(err, "unable to check for faststart")
+	if err := lm.ReadStreamTags(outb.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "unable to read stream tags")
+	}
+	if err := lm.ReadColor(outb.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "unable to read color properties")
+	}
+	if err := lm.ReadRotation(outb.Bytes()); err != nil {
+		return nil, errors.Wrap(err, "unable to read rotation")
+	}
+	if err := e.checkContainer(input, lm); err != nil {
+		return nil, errors.Wrap(err, "container check failed")
 	}
 	return lm, nil
 }
 
-func (e encoder) checkFastStart(input string) (bool, error) {
-	cmd := exec.Command(e.ffmpegPath, "-v", "trace", "-i", input) // #nosec G204
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	_ = cmd.Run() // ffmpeg exits non-zero when no output specified
-
-	outputLines := strings.Split(out.String(), "\n")
-	var seenMdat bool
-
-	for _, line := range outputLines {
-		if strings.Contains(line, "mdat") {
-			seenMdat = true
-		}
-		if strings.Contains(line, "moov") {
-			if !seenMdat {
-				// moov appears before mdat -> fast start
-				return true, nil
-			}
-			// moov after mdat -> not fast start
-			return false, nil
-		}
+// ErrIncompleteInput is returned for inputs cut short, usually by an incomplete upload or download.
+// Unlike rejected inputs, these may turn out fine when downloaded again.
+var ErrIncompleteInput = errors.New("input is incomplete")
+
+// checkContainer reads top-level box layout of MP4 and MOV inputs, filling FastStart and Fragmented metadata.
+// Inputs with no movie box are rejected with *RejectionError, inputs with truncated media data fail
+// with ErrIncompleteInput. Other box structure problems are only logged since ffmpeg is usually able
+// to read such files. Inputs in other containers are not checked.
+func (e encoder) checkContainer(input string, meta *ladder.Metadata) error {
+	l, err := mp4box.ScanFile(input)
+	var te *mp4box.TruncatedError
+	switch {
+	case errors.Is(err, mp4box.ErrNotISOBMFF):
+		return nil
+	case errors.Is(err, mp4box.ErrNoMoov):
+		return reject(RejectContainer, "%v", err)
+	case errors.As(err, &te) && (te.Box.Type == "mdat" || te.Box.Type == "moov" || te.Box.Type == "moof"):
+		return fmt.Errorf("%w: %w", ErrIncompleteInput, err)
+	case err != nil && l == nil:
+		return err
+	case err != nil:
+		e.log.Warn("input box structure problem", "input", input, "err", err, "boxes", strings.Join(l.Order(), ","))
 	}
-	return false, nil
+	meta.FastStart = l.FastStart()
+	meta.Fragmented = l.Fragmented
+	return nil
 }
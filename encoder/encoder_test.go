@@ -1,6 +1,7 @@
 package encoder
 
 import (
+	"encoding/binary"
 	"fmt"
 	"os"
 	"path"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/pkg/logging/zapadapter"
+	"github.com/OdyseeTeam/transcoder/pkg/mp4box"
 	"github.com/OdyseeTeam/transcoder/pkg/resolve"
 
 	"github.com/shopspring/decimal"
@@ -54,16 +56,66 @@ func (s *encoderSuite) TearDownSuite() {
 
 func (s *encoderSuite) TestCheckFastStart() {
 	absPath, _ := filepath.Abs(s.file.Name())
-	e, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).SpritegenPath(""))
+	e, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).Sprites(nil))
 	s.Require().NoError(err)
 	m, err := e.GetMetadata(absPath)
 	s.Require().NoError(err)
 	s.False(m.FastStart)
 }
 
+// mp4Boxes builds a file consisting of empty top-level boxes of the given types and sizes.
+func mp4Boxes(t *testing.T, boxes ...string) string {
+	t.Helper()
+	var data []byte
+	for _, b := range boxes {
+		var typ string
+		var size, written uint32
+		_, err := fmt.Sscanf(b, "%4s:%d:%d", &typ, &size, &written)
+		require.NoError(t, err)
+		h := make([]byte, written)
+		binary.BigEndian.PutUint32(h, size)
+		copy(h[4:], typ)
+		data = append(data, h...)
+	}
+	name := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(name, data, 0600))
+	return name
+}
+
+func TestGetMetadataContainer(t *testing.T) {
+	e := stubEncoder(t, "")
+
+	m, err := e.GetMetadata(mp4Boxes(t, "ftyp:24:24", "moov:100:100", "mdat:1000:1000"))
+	require.NoError(t, err)
+	assert.True(t, m.FastStart)
+	assert.False(t, m.Fragmented)
+
+	m, err = e.GetMetadata(mp4Boxes(t, "ftyp:24:24", "mdat:1000:1000", "moov:100:100", "free:16:10"))
+	require.NoError(t, err)
+	assert.False(t, m.FastStart)
+
+	m, err = e.GetMetadata(mp4Boxes(t, "ftyp:24:24", "moov:100:100", "moof:20:20", "mdat:1000:1000"))
+	require.NoError(t, err)
+	assert.True(t, m.Fragmented)
+
+	_, err = e.GetMetadata(mp4Boxes(t, "ftyp:24:24", "moov:100:100", "mdat:1000:500"))
+	assert.ErrorContains(t, err, "container check failed: input is incomplete: mdat box at 124 is truncated")
+	assert.ErrorIs(t, err, ErrIncompleteInput)
+	var te *mp4box.TruncatedError
+	assert.ErrorAs(t, err, &te)
+	var re *RejectionError
+	assert.NotErrorAs(t, err, &re, "truncated input should not be rejected")
+
+	_, err = e.GetMetadata(mp4Boxes(t, "ftyp:24:24", "mdat:1000:1000"))
+	assert.ErrorContains(t, err, "container check failed: input rejected (container): moov box missing")
+	require.ErrorAs(t, err, &re)
+	assert.Equal(t, RejectContainer, re.Reason)
+	assert.NotErrorIs(t, err, ErrIncompleteInput)
+}
+
 func (s *encoderSuite) TestEncode() {
 	absPath, _ := filepath.Abs(s.file.Name())
-	cfg := Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).SpritegenPath("")
+	cfg := Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).Sprites(nil)
 	e, err := NewEncoder(cfg)
 	s.Require().NoError(err)
 
@@ -86,7 +138,7 @@ func (s *encoderSuite) TestEncode() {
 
 func TestTweakRealStreams(t *testing.T) {
 	t.Skip()
-	encoder, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).SpritegenPath(""))
+	encoder, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).Sprites(nil))
 	require.NoError(t, err)
 
 	testCases := []struct {
@@ -0,0 +1,136 @@
+package encoder
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// ErrorKind tells why ffmpeg failed.
+type ErrorKind string
+
+const (
+	ErrorUnknown          ErrorKind = "unknown"
+	ErrorCorruptInput     ErrorKind = "corrupt_input"
+	ErrorUnsupportedCodec ErrorKind = "unsupported_codec"
+	ErrorOutOfDisk        ErrorKind = "out_of_disk"
+	ErrorKilled           ErrorKind = "killed"
+
+	// stderrLines is the number of last ffmpeg stderr lines kept for error reporting.
+	stderrLines = 30
+)
+
+// errorPatterns maps ffmpeg stderr messages to failure kinds. Kinds listed earlier take precedence
+// when several are matched, since running out of disk often causes decoding errors to be logged as well.
+var errorPatterns = []struct {
+	kind     ErrorKind
+	messages []string
+}{
+	{ErrorKilled, []string{"received signal"}},
+	{ErrorOutOfDisk, []string{"No space left on device", "Disk quota exceeded"}},
+	{ErrorUnsupportedCodec, []string{"Decoder (codec", "Unknown decoder", "Unknown encoder", "Unsupported codec"}},
+	{ErrorCorruptInput, []string{"Invalid data found when processing input", "moov atom not found"}},
+}
+
+// recoverableMessages are errors ffmpeg logs for damaged packets or frames before carrying on,
+// which should not decide the failure kind when ffmpeg dies later for another reason.
+// Newer ffmpeg versions prefix them with the decoder context, e.g. "[vist#0:0/h264 @ 0x55d]".
+var recoverableMessages = []string{"Error while decoding stream", "Decoding error:"}
+
+// FfmpegError is returned when ffmpeg exits unsuccessfully.
+type FfmpegError struct {
+	Kind ErrorKind
+	// Stderr contains the last lines ffmpeg printed before exiting.
+	Stderr []string
+
+	err error
+}
+
+func (e *FfmpegError) Error() string {
+	msg := fmt.Sprintf("ffmpeg failed (%s): %v", e.Kind, e.err)
+	if len(e.Stderr) > 0 {
+		msg += ": " + e.Stderr[len(e.Stderr)-1]
+	}
+	return msg
+}
+
+func (e *FfmpegError) Unwrap() error {
+	return e.err
+}
+
+// Transient reports whether the same input may encode successfully when retried, possibly on another worker.
+func (e *FfmpegError) Transient() bool {
+	return e.Kind == ErrorOutOfDisk || e.Kind == ErrorKilled
+}
+
+// stderrRing keeps the last lines of ffmpeg stderr output and classifies every line it sees.
+type stderrRing struct {
+	lines []string
+	next  int
+	seen  map[ErrorKind]bool
+}
+
+func newStderrRing(size int) *stderrRing {
+	return &stderrRing{lines: make([]string, 0, size), seen: map[ErrorKind]bool{}}
+}
+
+func (r *stderrRing) add(line string) {
+	if line = strings.TrimSpace(line); line == "" {
+		return
+	}
+	if k := classifyLine(line); k != ErrorUnknown {
+		r.seen[k] = true
+	}
+	if len(r.lines) < cap(r.lines) {
+		r.lines = append(r.lines, line)
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+}
+
+// last returns kept lines, oldest first.
+func (r *stderrRing) last() []string {
+	return append(append([]string{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// kind returns the highest priority failure kind seen in the output.
+func (r *stderrRing) kind() ErrorKind {
+	for _, p := range errorPatterns {
+		if r.seen[p.kind] {
+			return p.kind
+		}
+	}
+	return ErrorUnknown
+}
+
+func classifyLine(line string) ErrorKind {
+	for _, m := range recoverableMessages {
+		if strings.Contains(line, m) {
+			return ErrorUnknown
+		}
+	}
+	for _, p := range errorPatterns {
+		for _, m := range p.messages {
+			if strings.Contains(line, m) {
+				return p.kind
+			}
+		}
+	}
+	return ErrorUnknown
+}
+
+// newFfmpegError wraps ffmpeg exit error, classifying it by the exit status and stderr output.
+func newFfmpegError(err error, stderr *stderrRing) *FfmpegError {
+	kind := stderr.kind()
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			kind = ErrorKilled
+		}
+	}
+	return &FfmpegError{Kind: kind, Stderr: stderr.last(), err: err}
+}
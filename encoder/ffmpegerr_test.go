@@ -0,0 +1,135 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStderrRing(t *testing.T) {
+	r := newStderrRing(3)
+	assert.Empty(t, r.last())
+	r.add("one")
+	r.add("  ")
+	r.add("two")
+	assert.Equal(t, []string{"one", "two"}, r.last())
+	for i := 3; i <= 7; i++ {
+		r.add(fmt.Sprintf("line %v", i))
+	}
+	assert.Equal(t, []string{"line 5", "line 6", "line 7"}, r.last())
+}
+
+func TestStderrRingKind(t *testing.T) {
+	cases := []struct {
+		lines []string
+		kind  ErrorKind
+	}{
+		{[]string{"Conversion failed!"}, ErrorUnknown},
+		{[]string{"in.mp4: Invalid data found when processing input"}, ErrorCorruptInput},
+		{[]string{"[mov,mp4,m4a,3gp,3g2,mj2 @ 0x55d] moov atom not found"}, ErrorCorruptInput},
+		{[]string{"Decoder (codec av1) not found for input stream #0:0"}, ErrorUnsupportedCodec},
+		{[]string{"Unknown encoder 'libx265'"}, ErrorUnsupportedCodec},
+		{[]string{
+			"Error while decoding stream #0:0: Invalid data found when processing input",
+			"[hls @ 0x55d] Failed to open file 'v0_s000012.ts': No space left on device",
+		}, ErrorOutOfDisk},
+		{[]string{"Exiting normally, received signal 15."}, ErrorKilled},
+		{[]string{
+			"Error while decoding stream #0:0: Invalid data found when processing input",
+			"[h264 @ 0x55e] Could not find codec parameters for stream 1 (Video: h264, none): unspecified size",
+			"[hls @ 0x55d] Error writing trailer of v0.m3u8: Broken pipe",
+			"Conversion failed!",
+		}, ErrorUnknown},
+		{[]string{
+			"[vist#0:0/h264 @ 0x55d] Error while decoding stream #0:0: Invalid data found when processing input",
+			"[dec:h264 @ 0x55e] Decoding error: Invalid data found when processing input",
+			"[out#0/hls @ 0x55f] Error writing trailer: Broken pipe",
+			"Conversion failed!",
+		}, ErrorUnknown},
+		{[]string{
+			"[dec:h264 @ 0x55e] Decoding error: Invalid data found when processing input",
+			"[hls @ 0x55d] Failed to open file 'v0_s000012.ts': No space left on device",
+		}, ErrorOutOfDisk},
+	}
+	for _, c := range cases {
+		r := newStderrRing(stderrLines)
+		for _, l := range c.lines {
+			r.add(l)
+		}
+		assert.Equal(t, c.kind, r.kind(), c.lines)
+	}
+}
+
+func TestNewFfmpegError(t *testing.T) {
+	r := newStderrRing(stderrLines)
+	r.add("in.mp4: Invalid data found when processing input")
+
+	err := exec.Command("sh", "-c", "exit 1").Run()
+	fe := newFfmpegError(err, r)
+	assert.Equal(t, ErrorCorruptInput, fe.Kind)
+	assert.False(t, fe.Transient())
+	assert.Equal(t, "ffmpeg failed (corrupt_input): exit status 1: in.mp4: Invalid data found when processing input", fe.Error())
+	var ee *exec.ExitError
+	assert.True(t, errors.As(fe, &ee))
+
+	err = exec.Command("sh", "-c", "kill -9 $$").Run()
+	fe = newFfmpegError(err, r)
+	assert.Equal(t, ErrorKilled, fe.Kind)
+	assert.True(t, fe.Transient())
+}
+
+func TestParseProgressLine(t *testing.T) {
+	p, ok := parseProgressLine("frame=  120 fps= 60 q=28.0 size=    512kB time=00:00:04.00 bitrate=1048.6kbits/s speed=2.01x", 16)
+	require.True(t, ok)
+	assert.Equal(t, "120", p.FramesProcessed)
+	assert.Equal(t, "00:00:04.00", p.CurrentTime)
+	assert.Equal(t, "1048.6kbits/s", p.CurrentBitrate)
+	assert.Equal(t, "2.01x", p.Speed)
+	assert.EqualValues(t, 25, p.Progress)
+
+	_, ok = parseProgressLine("Stream mapping:", 16)
+	assert.False(t, ok)
+}
+
+func TestEncodeFfmpegFailure(t *testing.T) {
+	e := stubEncoder(t, `printf 'frame=   10 fps=0.0 q=28.0 size=N/A time=00:00:30.00 bitrate=N/A speed=1x\r' >&2
+echo "[hls @ 0x55d] Failed to open file 'v0_s000012.ts': No space left on device" >&2
+echo "Conversion failed!" >&2
+exit 1
+`)
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+
+	res, err := e.Encode(in, out)
+	require.NoError(t, err)
+
+	var progress []float64
+	done := make(chan struct{})
+	go func() {
+		for p := range res.Progress {
+			progress = append(progress, p.GetProgress())
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("progress channel not closed after ffmpeg exited")
+	}
+	assert.Equal(t, []float64{50}, progress)
+
+	var fe *FfmpegError
+	require.ErrorAs(t, res.Err(), &fe)
+	assert.Equal(t, ErrorOutOfDisk, fe.Kind)
+	assert.True(t, fe.Transient())
+	assert.Equal(t, "Conversion failed!", fe.Stderr[len(fe.Stderr)-1])
+	assert.NoDirExists(t, out)
+}
@@ -0,0 +1,211 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	streamInfTag = "#EXT-X-STREAM-INF:"
+	mediaTag     = "#EXT-X-MEDIA:"
+)
+
+var (
+	variantURIRe   = regexp.MustCompile(`^v(\d+)\.m3u8$`)
+	codecsAttrRe   = regexp.MustCompile(`CODECS="[^"]*"`)
+	mediaURIAttrRe = regexp.MustCompile(`URI="v(\d+)\.m3u8"`)
+)
+
+// fixMasterPlaylist sets CODECS attribute for variants produced by encoders which ffmpeg HLS muxer
+// cannot describe properly (it only knows how to make codec strings for H.264 and some audio codecs).
+// For shared audio renditions, audio codec is added to CODECS of video variants since ffmpeg omits it there,
+// and audio rendition names, languages and selection flags are set from source audio tracks.
+// Variants preserving HDR get VIDEO-RANGE attribute.
+// Subtitle renditions extracted separately are declared in the master playlist and attached to every variant.
+func fixMasterPlaylist(dir string, l ladder.Ladder, subs []subtitleRendition) error {
+	if l.IsAudioOnly() {
+		return nil
+	}
+	p := path.Join(dir, MasterPlaylist)
+	cont, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(cont), "\n")
+	names := audioRenditionNames(l.AudioTracks())
+	subsAdded := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, mediaTag) && strings.Contains(line, "TYPE=AUDIO") {
+			lines[i], err = fixAudioRendition(line, l, names)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, streamInfTag) {
+			continue
+		}
+		n, err := variantIndex(lines[i+1:])
+		if err != nil {
+			return err
+		}
+		if n >= len(l.Tiers) {
+			return fmt.Errorf("variant %v is missing from the ladder", n)
+		}
+		lines[i] = fixVariant(line, l, n)
+		if len(subs) > 0 {
+			lines[i] = setAttr(lines[i], "SUBTITLES", strconv.Quote(ladder.SubtitleGroup))
+			if !subsAdded {
+				lines[i] = strings.Join(append(subtitleRenditions(subs), lines[i]), "\n")
+				subsAdded = true
+			}
+		}
+	}
+
+	return os.WriteFile(p, []byte(strings.Join(lines, "\n")), 0644) // #nosec G306
+}
+
+// fixVariant sets CODECS and VIDEO-RANGE attributes of EXT-X-STREAM-INF tag for the tier number n.
+func fixVariant(line string, l ladder.Ladder, n int) string {
+	if l.Tiers[n].HDR && l.Metadata != nil {
+		line = setAttr(line, "VIDEO-RANGE", l.Metadata.Color.VideoRange())
+	}
+	h264 := l.Tiers[n].Codec == "" || l.Tiers[n].Codec == ladder.CodecH264
+	if h264 && !l.SharedAudio() {
+		return line
+	}
+	codecs := l.VideoCodecTag(n)
+	if h264 {
+		if m := codecsAttrRe.FindString(line); m != "" {
+			codecs = strings.TrimSuffix(strings.TrimPrefix(m, `CODECS="`), `"`)
+		}
+	}
+	if l.Metadata != nil && l.Metadata.HasAudio && !strings.Contains(codecs, ladder.AudioCodecTag) {
+		codecs += "," + ladder.AudioCodecTag
+	}
+	return setAttr(line, "CODECS", strconv.Quote(codecs))
+}
+
+// fixAudioRendition sets attributes of EXT-X-MEDIA tag from the source audio track it was encoded from.
+func fixAudioRendition(line string, l ladder.Ladder, names []string) (string, error) {
+	m := mediaURIAttrRe.FindStringSubmatch(line)
+	if m == nil {
+		return line, nil
+	}
+	n, err := strconv.Atoi(m[1])
+	if err != nil {
+		return "", err
+	}
+	tracks := l.AudioTracks()
+	n -= len(l.Tiers)
+	if n < 0 || n >= len(tracks) {
+		return "", fmt.Errorf("audio rendition %v is missing from the ladder", m[1])
+	}
+	t := tracks[n]
+	def := n == l.DefaultAudioTrack()
+
+	line = setAttr(line, "NAME", strconv.Quote(names[n]))
+	if t.Language != "" {
+		line = setAttr(line, "LANGUAGE", strconv.Quote(t.Language))
+	}
+	line = setAttr(line, "DEFAULT", yesNo(def))
+	line = setAttr(line, "AUTOSELECT", yesNo(def || (t.Language != "" && !t.Commentary)))
+	return line, nil
+}
+
+// subtitleRenditions makes EXT-X-MEDIA tags for subtitle renditions.
+// The first forced track is made default so players show it without user action.
+func subtitleRenditions(subs []subtitleRendition) []string {
+	tags := []string{}
+	names := map[string]int{}
+	defSet := false
+	for i, s := range subs {
+		name := s.Title
+		if name == "" {
+			name = s.Language
+		}
+		if name == "" {
+			name = fmt.Sprintf("Subtitles %d", i+1)
+		}
+		names[name]++
+		if names[name] > 1 {
+			name = fmt.Sprintf("%s %d", name, names[name])
+		}
+		def := s.Forced && !defSet
+		defSet = defSet || def
+		tag := fmt.Sprintf(`%sTYPE=SUBTITLES,GROUP-ID="%s",NAME=%s`, mediaTag, ladder.SubtitleGroup, strconv.Quote(name))
+		if s.Language != "" {
+			tag += ",LANGUAGE=" + strconv.Quote(s.Language)
+		}
+		tag += fmt.Sprintf(
+			",DEFAULT=%s,AUTOSELECT=%s,FORCED=%s,URI=%s",
+			yesNo(def), yesNo(def || s.Language != ""), yesNo(s.Forced), strconv.Quote(s.URI))
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// audioRenditionNames makes unique human-readable names for audio tracks.
+func audioRenditionNames(tracks []ladder.AudioTrack) []string {
+	names := make([]string, len(tracks))
+	seen := map[string]int{}
+	for i, t := range tracks {
+		n := t.Title
+		if n == "" {
+			n = t.Language
+		}
+		if n == "" {
+			n = fmt.Sprintf("Audio %d", i+1)
+		}
+		seen[n]++
+		if seen[n] > 1 {
+			n = fmt.Sprintf("%s %d", n, seen[n])
+		}
+		names[i] = n
+	}
+	return names
+}
+
+// setAttr replaces value of the attribute in HLS tag line, adding the attribute if it is not present.
+// Value should be quoted by the caller for quoted-string attributes.
+func setAttr(line, name, value string) string {
+	re := regexp.MustCompile(`([:,])` + name + `=("[^"]*"|[^,]*)`)
+	if !re.MatchString(line) {
+		return line + "," + name + "=" + value
+	}
+	return re.ReplaceAllStringFunc(line, func(m string) string {
+		return m[:1] + name + "=" + value
+	})
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "YES"
+	}
+	return "NO"
+}
+
+// variantIndex finds the first URI line and returns the tier number it was generated for.
+func variantIndex(lines []string) (int, error) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		m := variantURIRe.FindStringSubmatch(line)
+		if m == nil {
+			return 0, fmt.Errorf("unexpected variant playlist name: %s", line)
+		}
+		return strconv.Atoi(m[1])
+	}
+	return 0, errors.New("variant playlist URI missing")
+}
@@ -0,0 +1,212 @@
+package encoder
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/floostack/transcoder/ffmpeg"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixMasterPlaylist(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=2200000,RESOLUTION=1920x1080,CODECS="mp4a.40.2"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=1700000,RESOLUTION=1280x720
+v1.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+v2.m3u8
+`
+	require.NoError(t, os.WriteFile(path.Join(dir, MasterPlaylist), []byte(master), 0600))
+
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{VideoStream: ffmpeg.Streams{CodecType: "video"}, HasAudio: true, FPS: &ladder.FPS{Ratio: "30/1", Float: 30}},
+		Tiers: []ladder.Tier{
+			{Width: 1920, Height: 1080, Codec: ladder.CodecAV1},
+			{Width: 1280, Height: 720, Codec: ladder.CodecHEVC},
+			{Width: 640, Height: 360},
+		},
+	}
+	require.NoError(t, fixMasterPlaylist(dir, l, nil))
+
+	cont, err := os.ReadFile(path.Join(dir, MasterPlaylist))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=2200000,RESOLUTION=1920x1080,CODECS="av01.0.08M.08,mp4a.40.2"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=1700000,RESOLUTION=1280x720,CODECS="hvc1.1.6.L93.B0,mp4a.40.2"
+v1.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+v2.m3u8
+`, string(cont))
+}
+
+func TestFixMasterPlaylistSharedAudio(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_0",DEFAULT=YES,URI="v2.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=2200000,RESOLUTION=1920x1080,CODECS="avc1.640028",AUDIO="group_audio"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=1700000,RESOLUTION=1280x720,AUDIO="group_audio"
+v1.m3u8
+`
+	require.NoError(t, os.WriteFile(path.Join(dir, MasterPlaylist), []byte(master), 0600))
+
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{VideoStream: ffmpeg.Streams{CodecType: "video"}, HasAudio: true, FPS: &ladder.FPS{Ratio: "30/1", Float: 30}},
+		Tiers: []ladder.Tier{
+			{Width: 1920, Height: 1080},
+			{Width: 1280, Height: 720, Codec: ladder.CodecHEVC},
+		},
+		Audio: ladder.Audio{Shared: true, Bitrate: "128k"},
+	}
+	require.NoError(t, fixMasterPlaylist(dir, l, nil))
+
+	cont, err := os.ReadFile(path.Join(dir, MasterPlaylist))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="Audio 1",DEFAULT=YES,URI="v2.m3u8",AUTOSELECT=YES
+#EXT-X-STREAM-INF:BANDWIDTH=2200000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2",AUDIO="group_audio"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=1700000,RESOLUTION=1280x720,AUDIO="group_audio",CODECS="hvc1.1.6.L93.B0,mp4a.40.2"
+v1.m3u8
+`, string(cont))
+
+	l.Metadata.VideoStream = nil
+	l.Tiers = []ladder.Tier{}
+	require.NoError(t, fixMasterPlaylist(t.TempDir(), l, nil), "audio-only ladders are left as is")
+}
+
+func TestFixMasterPlaylistAudioTracks(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_0",DEFAULT=YES,URI="v1.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_1",DEFAULT=YES,URI="v2.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_2",DEFAULT=YES,URI="v3.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_3",LANGUAGE="eng",DEFAULT=YES,URI="v4.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f",AUDIO="group_audio"
+v0.m3u8
+`
+	require.NoError(t, os.WriteFile(path.Join(dir, MasterPlaylist), []byte(master), 0600))
+
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{
+			VideoStream: ffmpeg.Streams{CodecType: "video"},
+			HasAudio:    true,
+			FPS:         &ladder.FPS{Ratio: "30/1", Float: 30},
+			AudioTracks: []ladder.AudioTrack{
+				{Index: 0, Language: "en", Title: "English"},
+				{Index: 1, Language: "es", Default: true},
+				{Index: 2},
+				{Index: 3, Language: "en", Title: "English", Commentary: true},
+			},
+		},
+		Tiers: []ladder.Tier{{Width: 1280, Height: 720}},
+	}
+	require.True(t, l.SharedAudio())
+	require.NoError(t, fixMasterPlaylist(dir, l, nil))
+
+	cont, err := os.ReadFile(path.Join(dir, MasterPlaylist))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="English",DEFAULT=NO,URI="v1.m3u8",LANGUAGE="en",AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="es",DEFAULT=YES,URI="v2.m3u8",LANGUAGE="es",AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="Audio 3",DEFAULT=NO,URI="v3.m3u8",AUTOSELECT=NO
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="English 2",LANGUAGE="en",DEFAULT=NO,URI="v4.m3u8",AUTOSELECT=NO
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",AUDIO="group_audio"
+v0.m3u8
+`, string(cont))
+}
+
+func TestFixMasterPlaylistSubtitles(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2"
+v1.m3u8
+`
+	require.NoError(t, os.WriteFile(path.Join(dir, MasterPlaylist), []byte(master), 0600))
+
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{VideoStream: ffmpeg.Streams{CodecType: "video"}, HasAudio: true, FPS: &ladder.FPS{Ratio: "30/1", Float: 30}},
+		Tiers:    []ladder.Tier{{Width: 1280, Height: 720}, {Width: 640, Height: 360}},
+	}
+	subs := []subtitleRendition{
+		{SubtitleTrack: ladder.SubtitleTrack{Index: 0, Language: "en"}, URI: "sub0.m3u8"},
+		{SubtitleTrack: ladder.SubtitleTrack{Index: 2, Language: "en", Title: "Signs", Forced: true}, URI: "sub1.m3u8"},
+		{SubtitleTrack: ladder.SubtitleTrack{Index: 3}, URI: "sub2.m3u8"},
+	}
+	require.NoError(t, fixMasterPlaylist(dir, l, subs))
+
+	cont, err := os.ReadFile(path.Join(dir, MasterPlaylist))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subtitles",NAME="en",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI="sub0.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subtitles",NAME="Signs",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,FORCED=YES,URI="sub1.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subtitles",NAME="Subtitles 3",DEFAULT=NO,AUTOSELECT=NO,FORCED=NO,URI="sub2.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",SUBTITLES="subtitles"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2",SUBTITLES="subtitles"
+v1.m3u8
+`, string(cont))
+}
+
+func TestFixMasterPlaylistHDR(t *testing.T) {
+	dir := t.TempDir()
+	master := `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=3800000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=4800000,RESOLUTION=1920x1080
+v1.m3u8
+`
+	require.NoError(t, os.WriteFile(path.Join(dir, MasterPlaylist), []byte(master), 0600))
+
+	l := ladder.Ladder{
+		Metadata: &ladder.Metadata{
+			VideoStream: ffmpeg.Streams{CodecType: "video"},
+			HasAudio:    true,
+			FPS:         &ladder.FPS{Ratio: "24/1", Float: 24},
+			Color:       ladder.Color{Transfer: ladder.TransferPQ, BitDepth: 10},
+		},
+		Tiers: []ladder.Tier{
+			{Width: 1920, Height: 1080},
+			{Width: 1920, Height: 1080, Codec: ladder.CodecHEVC, HDR: true},
+		},
+	}
+	require.NoError(t, fixMasterPlaylist(dir, l, nil))
+
+	cont, err := os.ReadFile(path.Join(dir, MasterPlaylist))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=3800000,RESOLUTION=1920x1080,CODECS="avc1.640028,mp4a.40.2"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=4800000,RESOLUTION=1920x1080,VIDEO-RANGE=PQ,CODECS="hvc1.2.4.L120.B0,mp4a.40.2"
+v1.m3u8
+`, string(cont))
+}
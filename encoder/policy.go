@@ -0,0 +1,114 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+)
+
+// RejectReason tells which input policy limit the input violates.
+type RejectReason string
+
+const (
+	RejectDuration   RejectReason = "duration"
+	RejectResolution RejectReason = "resolution"
+	RejectFramerate  RejectReason = "framerate"
+	RejectBitrate    RejectReason = "bitrate"
+	RejectCodec      RejectReason = "codec"
+	// RejectContainer is used for inputs which container cannot be played back, regardless of input policy.
+	RejectContainer RejectReason = "container"
+)
+
+// InputPolicy defines limits inputs are checked against before encoding. Zero limits and empty codec lists
+// are not enforced.
+type InputPolicy struct {
+	// MaxDuration is the maximum input duration in seconds. Inputs with unknown duration are always rejected.
+	MaxDuration float64
+	// MaxWidth and MaxHeight limit video resolution regardless of orientation, so the default 3840x2160 limit
+	// allows portrait 2160x3840 videos too.
+	MaxWidth, MaxHeight int
+	MaxFramerate        float64
+	// MaxBitrate is the maximum overall input bitrate in bits per second. Unknown bitrate is not checked.
+	MaxBitrate int
+	// VideoCodecs and AudioCodecs list allowed codecs by ffprobe codec name.
+	VideoCodecs, AudioCodecs []string
+}
+
+var DefaultInputPolicy = InputPolicy{
+	MaxDuration:  6 * 60 * 60,
+	MaxWidth:     3840,
+	MaxHeight:    2160,
+	MaxFramerate: 120,
+	MaxBitrate:   200_000_000,
+}
+
+// RejectionError is returned when the input violates input policy. Such inputs fail the same way every time
+// and should not be retried.
+type RejectionError struct {
+	Reason RejectReason
+	msg    string
+}
+
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("input rejected (%s): %s", e.Reason, e.msg)
+}
+
+func reject(reason RejectReason, format string, a ...any) *RejectionError {
+	return &RejectionError{Reason: reason, msg: fmt.Sprintf(format, a...)}
+}
+
+func (p InputPolicy) validate() error {
+	switch {
+	case p.MaxDuration < 0:
+		return fmt.Errorf("input policy max duration must not be negative, got %v", p.MaxDuration)
+	case p.MaxWidth < 0 || p.MaxHeight < 0:
+		return fmt.Errorf("input policy max resolution must not be negative, got %vx%v", p.MaxWidth, p.MaxHeight)
+	case p.MaxFramerate < 0:
+		return fmt.Errorf("input policy max frame rate must not be negative, got %v", p.MaxFramerate)
+	case p.MaxBitrate < 0:
+		return fmt.Errorf("input policy max bitrate must not be negative, got %v", p.MaxBitrate)
+	}
+	return nil
+}
+
+// Check returns *RejectionError for the first limit the input violates, nil if it satisfies the policy.
+func (p InputPolicy) Check(meta *ladder.Metadata) error {
+	format := meta.FMeta.GetFormat()
+	dur, err := strconv.ParseFloat(format.GetDuration(), 64)
+	if err != nil || dur <= 0 || math.IsInf(dur, 0) || math.IsNaN(dur) {
+		return reject(RejectDuration, "duration %q is unknown", format.GetDuration())
+	}
+	if p.MaxDuration > 0 && dur > p.MaxDuration {
+		return reject(RejectDuration, "duration %.0fs exceeds %.0fs", dur, p.MaxDuration)
+	}
+	if btr, _ := strconv.Atoi(format.GetBitRate()); p.MaxBitrate > 0 && btr > p.MaxBitrate {
+		return reject(RejectBitrate, "bitrate %d exceeds %d", btr, p.MaxBitrate)
+	}
+	if meta.HasAudio && !allowed(p.AudioCodecs, meta.AudioStream.GetCodecName()) {
+		return reject(RejectCodec, "audio codec %q is not allowed", meta.AudioStream.GetCodecName())
+	}
+	if !meta.HasVideo() {
+		return nil
+	}
+
+	vs := meta.VideoStream
+	if !allowed(p.VideoCodecs, vs.GetCodecName()) {
+		return reject(RejectCodec, "video codec %q is not allowed", vs.GetCodecName())
+	}
+	long, short := max(vs.GetWidth(), vs.GetHeight()), min(vs.GetWidth(), vs.GetHeight())
+	maxLong, maxShort := max(p.MaxWidth, p.MaxHeight), min(p.MaxWidth, p.MaxHeight)
+	if (maxLong > 0 && long > maxLong) || (maxShort > 0 && short > maxShort) {
+		return reject(RejectResolution, "resolution %dx%d exceeds %dx%d", vs.GetWidth(), vs.GetHeight(), p.MaxWidth, p.MaxHeight)
+	}
+	if p.MaxFramerate > 0 && meta.FPS != nil && meta.FPS.Float > p.MaxFramerate {
+		return reject(RejectFramerate, "frame rate %s exceeds %v", meta.FPS.String(), p.MaxFramerate)
+	}
+	return nil
+}
+
+func allowed(codecs []string, name string) bool {
+	return len(codecs) == 0 || slices.Contains(codecs, name)
+}
@@ -0,0 +1,70 @@
+package encoder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInputPolicyCheck(t *testing.T) {
+	e := stubEncoder(t, "")
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	meta, err := e.GetMetadata(in)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		policy InputPolicy
+		reason RejectReason
+		msg    string
+	}{
+		{"default", DefaultInputPolicy, "", ""},
+		{"no limits", InputPolicy{}, "", ""},
+		{"duration", InputPolicy{MaxDuration: 30}, RejectDuration, "duration 60s exceeds 30s"},
+		{"bitrate", InputPolicy{MaxBitrate: 1_000_000}, RejectBitrate, "bitrate 2000000 exceeds 1000000"},
+		{"resolution", InputPolicy{MaxWidth: 854, MaxHeight: 480}, RejectResolution, "resolution 1280x720 exceeds 854x480"},
+		{"portrait limit", InputPolicy{MaxWidth: 720, MaxHeight: 1280}, "", ""},
+		{"framerate", InputPolicy{MaxFramerate: 25}, RejectFramerate, "frame rate 30/1 exceeds 25"},
+		{"video codec", InputPolicy{VideoCodecs: []string{"h264", "vp9"}}, RejectCodec, `video codec "" is not allowed`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Check(meta)
+			if tc.reason == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var re *RejectionError
+			require.ErrorAs(t, err, &re)
+			assert.Equal(t, tc.reason, re.Reason)
+			assert.EqualError(t, err, "input rejected ("+string(tc.reason)+"): "+tc.msg)
+		})
+	}
+}
+
+func TestEncodeRejected(t *testing.T) {
+	ffprobe := path.Join(t.TempDir(), "ffprobe")
+	probeOutput := strings.Replace(stubProbeOutput, `"duration": "60.0"`, `"duration": "N/A"`, 1)
+	require.NoError(t, os.WriteFile(ffprobe, []byte("#!/bin/sh\ncat <<'EOF'\n"+probeOutput+"\nEOF\n"), 0700)) // #nosec G306
+
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	out := path.Join(t.TempDir(), "out")
+
+	e, err := NewEncoder(stubConfig(t, stubFfmpegBlocking).FfprobePath(ffprobe))
+	require.NoError(t, err)
+	_, err = e.Encode(in, out)
+	var re *RejectionError
+	require.ErrorAs(t, err, &re)
+	assert.Equal(t, RejectDuration, re.Reason)
+	assert.ErrorContains(t, err, `duration "N/A" is unknown`)
+	assert.NoDirExists(t, out)
+
+	_, err = NewEncoder(stubConfig(t, "").InputPolicy(&InputPolicy{MaxBitrate: -1}))
+	assert.ErrorContains(t, err, "max bitrate must not be negative")
+}
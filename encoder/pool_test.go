@@ -43,7 +43,7 @@ func (s *poolSuite) TearDownSuite() {
 
 func (s *poolSuite) TestEncode() {
 	absPath, _ := filepath.Abs(s.file.Name())
-	enc, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).SpritegenPath(""))
+	enc, err := NewEncoder(Configure().Log(zapadapter.NewKV(nil)).Ladder(ladder.Default).Sprites(nil))
 	s.Require().NoError(err)
 	p := NewPool(enc, 10)
 
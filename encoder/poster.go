@@ -0,0 +1,279 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	PosterName = "poster.jpg"
+
+	PreviewFormatWebP = "webp"
+	PreviewFormatMP4  = "mp4"
+
+	// posterMaxBlack is the percentage of black pixels above which a frame is never picked for a poster.
+	posterMaxBlack = 90
+	// posterEdgeSkip is the share of the video at its start and end (intros, credits) avoided for a poster.
+	posterEdgeSkip = 0.05
+)
+
+// PosterConfig defines poster frame dimensions.
+type PosterConfig struct {
+	// Width of the poster, videos narrower than that keep their own width.
+	Width int
+}
+
+// PreviewConfig defines animated preview clip, which starts at the poster frame.
+type PreviewConfig struct {
+	// Format is either webp (animated WebP) or mp4 (H.264 without audio).
+	Format string
+	// Duration of the clip in seconds.
+	Duration float64
+	// Width of the clip, videos narrower than that keep their own width.
+	Width int
+	// Framerate of the clip.
+	Framerate int
+}
+
+var DefaultPosterConfig = PosterConfig{Width: 1280}
+
+var DefaultPreviewConfig = PreviewConfig{
+	Format:    PreviewFormatWebP,
+	Duration:  3,
+	Width:     320,
+	Framerate: 10,
+}
+
+// posterCandidate is a video keyframe with its scoring metrics.
+type posterCandidate struct {
+	time float64
+	// pblack is the percentage of black pixels, as measured by blackframe filter.
+	pblack float64
+	// entropy is normalized luma entropy (0..1) from entropy filter, flat frames score low.
+	entropy float64
+	// scene is the scene change score (0..1) relative to the previous keyframe.
+	scene float64
+}
+
+// score rates the frame as a poster: detailed frames score higher, black and fading ones lower.
+// Frames starting a new scene get a bonus, since a frame right after a cut is usually sharp
+// and shows what the scene is about, while frames between cuts are often mid-transition.
+func (c posterCandidate) score() float64 {
+	if c.pblack > posterMaxBlack {
+		return 0
+	}
+	return c.entropy * (1 - c.pblack/100) * (1 + math.Min(c.scene, 0.5))
+}
+
+func (c PreviewConfig) name() string {
+	return "preview." + c.Format
+}
+
+func (c PreviewConfig) validate() error {
+	switch {
+	case c.Format != PreviewFormatWebP && c.Format != PreviewFormatMP4:
+		return fmt.Errorf("unknown preview format %q", c.Format)
+	case c.Duration <= 0:
+		return fmt.Errorf("preview duration must be positive, got %v", c.Duration)
+	case c.Width <= 0 || c.Width%2 != 0:
+		return fmt.Errorf("preview width must be positive and even, got %v", c.Width)
+	case c.Framerate <= 0:
+		return fmt.Errorf("preview framerate must be positive, got %v", c.Framerate)
+	}
+	return nil
+}
+
+// scaledSize returns dimensions for the video scaled down to width, keeping its display aspect ratio.
+// Videos narrower than width are not upscaled.
+func scaledSize(meta *ladder.Metadata, width int) (int, int) {
+	w, h := meta.DisplaySize()
+	if w == 0 || h == 0 {
+		return width, roundEven(float64(width) * 9 / 16)
+	}
+	if w < width {
+		return w, h
+	}
+	return width, max(2, roundEven(float64(width*h)/float64(w)))
+}
+
+func roundEven(v float64) int {
+	return int(math.Round(v/2)) * 2
+}
+
+// generateStills writes poster frame and, if configured, animated preview into output directory.
+// Failures are logged only since neither is essential for playback.
+func (e encoder) generateStills(ctx context.Context, input, output string, meta *ladder.Metadata, ll logging.KVLogger) {
+	if e.poster == nil && e.preview == nil {
+		return
+	}
+	dur, _ := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
+	cands, err := e.analyzeKeyframes(ctx, input)
+	if err != nil {
+		ll.Warn("keyframe analysis failed, using the first frame", "err", err)
+	}
+	at := pickPosterTime(cands, dur)
+	ll.Info("poster frame picked", "time", at, "candidates", len(cands))
+
+	if e.poster != nil {
+		w, h := scaledSize(meta, e.poster.Width)
+		if err := e.runFfmpeg(ctx, posterArguments(input, path.Join(output, PosterName), at, w, h)); err != nil {
+			ll.Warn("could not extract poster", "err", err)
+		}
+	}
+	if e.preview != nil {
+		p := *e.preview
+		start := math.Max(0, math.Min(at, dur-p.Duration))
+		w, h := scaledSize(meta, p.Width)
+		if err := e.runFfmpeg(ctx, p.arguments(input, path.Join(output, p.name()), start, w, h)); err != nil {
+			ll.Warn("could not make preview", "err", err)
+		}
+	}
+}
+
+// analyzeKeyframes decodes video keyframes only and measures their black pixel share, entropy and scene change score.
+func (e encoder) analyzeKeyframes(ctx context.Context, input string) ([]posterCandidate, error) {
+	var errb bytes.Buffer
+	args := []string{
+		"-hide_banner", "-nostdin", "-loglevel", "info",
+		"-skip_frame", "nokey", "-i", input,
+		"-map", "0:v:0", "-an", "-sn",
+		"-filter:v", "select='gte(scene,0)',scale=320:-2,blackframe=amount=0,entropy,metadata=mode=print",
+		"-fps_mode", "passthrough", "-f", "null", "-",
+	}
+	cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, lastLines(errb.String(), 5))
+	}
+	return parseKeyframeMetadata(errb.String()), nil
+}
+
+// parseKeyframeMetadata reads frame metadata printed by ffmpeg metadata filter:
+//
+//	[Parsed_metadata_4 @ 0x5581] frame:0    pts:0       pts_time:0
+//	[Parsed_metadata_4 @ 0x5581] lavfi.blackframe.pblack=12
+func parseKeyframeMetadata(log string) []posterCandidate {
+	cands := []posterCandidate{}
+	sc := bufio.NewScanner(strings.NewReader(log))
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, "[Parsed_metadata_") {
+			continue
+		}
+		_, kv, ok := strings.Cut(line, "] ")
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(kv, "frame:") {
+			c := posterCandidate{}
+			for _, f := range strings.Fields(kv) {
+				if v, ok := strings.CutPrefix(f, "pts_time:"); ok {
+					c.time, _ = strconv.ParseFloat(v, 64)
+				}
+			}
+			cands = append(cands, c)
+			continue
+		}
+		if len(cands) == 0 {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			continue
+		}
+		c := &cands[len(cands)-1]
+		switch k {
+		case "lavfi.blackframe.pblack":
+			c.pblack = f
+		case "lavfi.entropy.normalized_entropy.normal.Y":
+			c.entropy = f
+		case "lavfi.scene_score":
+			c.scene = f
+		}
+	}
+	return cands
+}
+
+// pickPosterTime returns time of the best scoring candidate, preferring ones away from the start and end
+// of the video. Zero is returned if no candidate scores above zero.
+func pickPosterTime(cands []posterCandidate, duration float64) float64 {
+	var best *posterCandidate
+	var bestScore float64
+	for _, skipEdges := range []bool{true, false} {
+		for i := range cands {
+			c := &cands[i]
+			if skipEdges && duration > 0 && (c.time < duration*posterEdgeSkip || c.time > duration*(1-posterEdgeSkip)) {
+				continue
+			}
+			if s := c.score(); s > bestScore {
+				best, bestScore = c, s
+			}
+		}
+		if best != nil {
+			return best.time
+		}
+	}
+	return 0
+}
+
+func posterArguments(input, output string, at float64, w, h int) []string {
+	return []string{
+		"-hide_banner", "-nostdin", "-loglevel", "error", "-y",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", input,
+		"-map", "0:v:0", "-frames:v", "1",
+		"-filter:v", fmt.Sprintf("scale=%d:%d,setsar=1", w, h),
+		"-q:v", "2",
+		output,
+	}
+}
+
+func (c PreviewConfig) arguments(input, output string, start float64, w, h int) []string {
+	args := []string{
+		"-hide_banner", "-nostdin", "-loglevel", "error", "-y",
+		"-ss", strconv.FormatFloat(start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(c.Duration, 'f', -1, 64),
+		"-i", input,
+		"-map", "0:v:0", "-an", "-sn",
+		"-filter:v", fmt.Sprintf("fps=%d,scale=%d:%d,setsar=1", c.Framerate, w, h),
+	}
+	if c.Format == PreviewFormatMP4 {
+		args = append(args,
+			"-c:v", string(ladder.CodecH264), "-pix_fmt", "yuv420p", "-crf", "28",
+			"-movflags", "+faststart", "-f", "mp4")
+	} else {
+		args = append(args, "-c:v", "libwebp", "-loop", "0", "-q:v", "60", "-f", "webp")
+	}
+	return append(args, output)
+}
+
+func (e encoder) runFfmpeg(ctx context.Context, args []string) error {
+	var errb bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, lastLines(errb.String(), 5))
+	}
+	return nil
+}
+
+// lastLines returns up to n last non-empty lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.Join(lines[max(0, len(lines)-n):], "\n")
+}
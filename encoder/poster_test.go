@@ -0,0 +1,92 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeyframeMetadata(t *testing.T) {
+	log := `Input #0, mov,mp4,m4a,3gp,3g2,mj2, from 'in.mp4':
+[Parsed_blackframe_2 @ 0x5581] frame:0 pblack:100 pts:0 t:0.000000 type:I last_keyframe:0
+[Parsed_metadata_4 @ 0x5581] frame:0    pts:0       pts_time:0
+[Parsed_metadata_4 @ 0x5581] lavfi.scene_score=0.000000
+[Parsed_metadata_4 @ 0x5581] lavfi.blackframe.pblack=100
+[Parsed_metadata_4 @ 0x5581] lavfi.entropy.entropy.normal.Y=0.000000
+[Parsed_metadata_4 @ 0x5581] lavfi.entropy.normalized_entropy.normal.Y=0.000000
+[Parsed_metadata_4 @ 0x5581] frame:1    pts:61440   pts_time:4.8
+[Parsed_metadata_4 @ 0x5581] lavfi.scene_score=0.612000
+[Parsed_metadata_4 @ 0x5581] lavfi.blackframe.pblack=3
+[Parsed_metadata_4 @ 0x5581] lavfi.entropy.normalized_entropy.normal.Y=0.870000
+frame=    2 fps=0.0 q=-0.0 Lsize=N/A time=00:00:04.80 bitrate=N/A speed= 100x
+`
+	assert.Equal(t, []posterCandidate{
+		{time: 0, pblack: 100},
+		{time: 4.8, pblack: 3, entropy: 0.87, scene: 0.612},
+	}, parseKeyframeMetadata(log))
+	assert.Empty(t, parseKeyframeMetadata("no metadata here"))
+}
+
+func TestPickPosterTime(t *testing.T) {
+	cands := []posterCandidate{
+		{time: 0, pblack: 0, entropy: 0.9},
+		{time: 10, pblack: 95, entropy: 0.9, scene: 0.8},
+		{time: 20, pblack: 10, entropy: 0.6},
+		{time: 30, pblack: 0, entropy: 0.6, scene: 0.4},
+		{time: 98, pblack: 0, entropy: 1},
+	}
+	assert.EqualValues(t, 30, pickPosterTime(cands, 100), "scene change should win over equal detail")
+	assert.EqualValues(t, 0, pickPosterTime(cands[:2], 100), "edge frames are used when nothing else fits")
+	assert.EqualValues(t, 0, pickPosterTime([]posterCandidate{{time: 5, pblack: 100}}, 100))
+	assert.EqualValues(t, 0, pickPosterTime(nil, 100))
+}
+
+func TestScaledSize(t *testing.T) {
+	cases := []struct {
+		w, h   int
+		sar    string
+		width  int
+		sw, sh int
+	}{
+		{1920, 1080, "", 1280, 1280, 720},
+		{640, 360, "", 1280, 640, 360},
+		{1080, 1920, "", 320, 320, 568},
+		{720, 576, "64:45", 320, 320, 180},
+	}
+	for _, c := range cases {
+		m, err := ladder.WrapMeta(&ffmpeg.Metadata{
+			Streams: []ffmpeg.Streams{{
+				CodecType: "video", Width: c.w, Height: c.h, SampleAspectRatio: c.sar, AvgFrameRate: "25/1",
+			}},
+		})
+		require.NoError(t, err)
+		w, h := scaledSize(m, c.width)
+		assert.Equal(t, c.sw, w, "%vx%v", c.w, c.h)
+		assert.Equal(t, c.sh, h, "%vx%v", c.w, c.h)
+	}
+}
+
+func TestPreviewArguments(t *testing.T) {
+	p := DefaultPreviewConfig
+	require.NoError(t, p.validate())
+	assert.Equal(t, "preview.webp", p.name())
+	args := strings.Join(p.arguments("in.mp4", "/out/preview.webp", 12.5, 320, 180), " ")
+	assert.Contains(t, args, "-ss 12.500 -t 3 -i in.mp4 -map 0:v:0 -an -sn -filter:v fps=10,scale=320:180,setsar=1")
+	assert.True(t, strings.HasSuffix(args, "-c:v libwebp -loop 0 -q:v 60 -f webp /out/preview.webp"))
+
+	p.Format = PreviewFormatMP4
+	assert.Equal(t, "preview.mp4", p.name())
+	args = strings.Join(p.arguments("in.mp4", "/out/preview.mp4", 0, 320, 180), " ")
+	assert.True(t, strings.HasSuffix(args, "-movflags +faststart -f mp4 /out/preview.mp4"))
+
+	p.Format = "gif"
+	assert.Error(t, p.validate())
+
+	args = strings.Join(posterArguments("in.mp4", "/out/poster.jpg", 30, 1280, 720), " ")
+	assert.Contains(t, args, "-ss 30.000 -i in.mp4 -map 0:v:0 -frames:v 1 -filter:v scale=1280:720,setsar=1")
+}
@@ -0,0 +1,111 @@
+package encoder
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	ffmpegt "github.com/floostack/transcoder"
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/floostack/transcoder/utils"
+)
+
+// progressValueSpaces matches padding ffmpeg puts between status line keys and values.
+var progressValueSpaces = regexp.MustCompile(`=\s+`)
+
+// ffmpegRun is a running ffmpeg process with its progress parsed from stderr.
+type ffmpegRun struct {
+	Progress <-chan ffmpegt.Progress
+	// err is set to *FfmpegError if ffmpeg exits unsuccessfully. It is only valid once Progress is closed.
+	err error
+}
+
+// startFfmpeg starts ffmpeg in dir and relays its progress, duration is used for calculating progress percentage.
+// Stderr lines other than progress reports are kept for classifying the failure if ffmpeg exits with an error.
+func (e encoder) startFfmpeg(ctx context.Context, dir string, args []string, duration float64) (*ffmpegRun, error) {
+	cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+	cmd.Dir = dir
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed starting ffmpeg: %w", err)
+	}
+
+	progress := make(chan ffmpegt.Progress)
+	run := &ffmpegRun{Progress: progress}
+	go func() {
+		defer close(progress)
+		ring := newStderrRing(stderrLines)
+		sc := bufio.NewScanner(stderr)
+		sc.Split(scanLinesCR)
+		for sc.Scan() {
+			if p, ok := parseProgressLine(sc.Text(), duration); ok {
+				progress <- p
+				continue
+			}
+			ring.add(sc.Text())
+		}
+		if err := cmd.Wait(); err != nil {
+			run.err = newFfmpegError(err, ring)
+		}
+	}()
+	return run, nil
+}
+
+// finishedRun returns a run which has already exited with err.
+func finishedRun(err error) *ffmpegRun {
+	progress := make(chan ffmpegt.Progress)
+	close(progress)
+	return &ffmpegRun{Progress: progress, err: err}
+}
+
+// scanLinesCR is a bufio.SplitFunc which splits on carriage returns as well as newlines,
+// since ffmpeg uses the former for updating progress status line.
+func scanLinesCR(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// parseProgressLine parses ffmpeg status line:
+//
+//	frame=  120 fps= 60 q=28.0 size=    512kB time=00:00:04.00 bitrate=1048.6kbits/s speed=2.01x
+func parseProgressLine(line string, duration float64) (ffmpeg.Progress, bool) {
+	p := ffmpeg.Progress{}
+	if !strings.Contains(line, "time=") || !strings.Contains(line, "bitrate=") {
+		return p, false
+	}
+	for _, f := range strings.Fields(progressValueSpaces.ReplaceAllString(line, "=")) {
+		k, v, ok := strings.Cut(f, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "frame":
+			p.FramesProcessed = v
+		case "time":
+			p.CurrentTime = v
+		case "bitrate":
+			p.CurrentBitrate = v
+		case "speed":
+			p.Speed = v
+		}
+	}
+	if duration > 0 {
+		p.Progress = utils.DurToSec(p.CurrentTime) * 100 / duration
+	}
+	return p, true
+}
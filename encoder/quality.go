@@ -0,0 +1,188 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/pkg/errors"
+)
+
+// maxPSNR caps PSNR of identical frames, which ffmpeg reports as infinite.
+const maxPSNR = 100
+
+var (
+	reSSIM = regexp.MustCompile(`SSIM .*All:([0-9.]+)`)
+	rePSNR = regexp.MustCompile(`PSNR .*average:([0-9.]+|inf)`)
+	reVMAF = regexp.MustCompile(`VMAF score: ([0-9.]+)`)
+)
+
+// QualityConfig defines objective quality measurement of encoded renditions against the source,
+// made on segments sampled across the video once encoding is done.
+type QualityConfig struct {
+	// Samples is the number of segments measured.
+	Samples int
+	// SampleDuration is the duration of a measured segment in seconds.
+	SampleDuration float64
+	// VMAF enables VMAF scoring in addition to SSIM and PSNR. It is skipped if ffmpeg is built without libvmaf.
+	VMAF bool
+}
+
+var DefaultQualityConfig = QualityConfig{
+	Samples:        3,
+	SampleDuration: 5,
+	VMAF:           true,
+}
+
+// Quality contains objective quality scores of a rendition, averaged over measured samples.
+type Quality struct {
+	Definition ladder.Definition
+	Codec      string
+	SSIM, PSNR float64
+	// VMAF is zero if it was not measured.
+	VMAF float64
+}
+
+func (c QualityConfig) validate() error {
+	switch {
+	case c.Samples <= 0:
+		return fmt.Errorf("quality samples must be positive, got %v", c.Samples)
+	case c.SampleDuration <= 0:
+		return fmt.Errorf("quality sample duration must be positive, got %v", c.SampleDuration)
+	}
+	return nil
+}
+
+// hasVMAF checks if ffmpeg has libvmaf filter.
+func hasVMAF(ffmpegPath string) bool {
+	out, err := exec.Command(ffmpegPath, "-hide_banner", "-filters").Output() // #nosec G204
+	if err != nil {
+		return false
+	}
+	for _, l := range strings.Split(string(out), "\n") {
+		if f := strings.Fields(l); len(f) > 1 && f[1] == "libvmaf" {
+			return true
+		}
+	}
+	return false
+}
+
+// scoreQuality measures quality of video renditions in the output and records it in the result.
+// Failures are logged only since scores are informational.
+func (e encoder) scoreQuality(ctx context.Context, res *Result, ll logging.KVLogger) {
+	qs, err := e.measureQuality(ctx, res, ll)
+	if err != nil {
+		ll.Warn("quality measurement failed", "err", err)
+		return
+	}
+	for _, q := range qs {
+		ll.Info("rendition quality measured", "tier", q.Definition, "codec", q.Codec, "ssim", q.SSIM, "psnr", q.PSNR, "vmaf", q.VMAF)
+	}
+	res.Quality = qs
+}
+
+// measureQuality compares samples of each video rendition in the output against the same samples of the source.
+// Tone mapped renditions of HDR sources are skipped as they are not comparable with the source.
+func (e encoder) measureQuality(ctx context.Context, res *Result, ll logging.KVLogger) ([]Quality, error) {
+	meta := res.OrigMeta
+	dur, err := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
+	if err != nil || dur <= 0 {
+		return nil, errors.New("cannot determine video duration")
+	}
+	w, h := meta.DisplaySize()
+	vmaf := e.quality.VMAF && e.vmaf
+	offsets := sampleOffsets(dur, e.quality.Samples, e.quality.SampleDuration)
+
+	qs := []Quality{}
+	for n, t := range res.Ladder.Tiers {
+		if meta.Color.IsHDR() && !t.HDR {
+			ll.Debug("skipping quality measurement of tone mapped tier", "tier", t.Definition)
+			continue
+		}
+		q := Quality{Definition: t.Definition, Codec: t.CodecName()}
+		rendition := path.Join(res.Output, fmt.Sprintf("v%d.m3u8", n))
+		for _, ss := range offsets {
+			args := qualityArguments(res.Input, rendition, ss, min(e.quality.SampleDuration, dur-ss), w, h, vmaf)
+			var errb bytes.Buffer
+			cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+			cmd.Stderr = &errb
+			if err := cmd.Run(); err != nil {
+				return nil, fmt.Errorf("tier %s sample at %.1fs failed: %w (%s)", t.Definition, ss, err, lastLines(errb.String(), 5))
+			}
+			s, err := parseQuality(errb.String(), vmaf)
+			if err != nil {
+				return nil, fmt.Errorf("tier %s sample at %.1fs: %w", t.Definition, ss, err)
+			}
+			q.SSIM += s.SSIM
+			q.PSNR += s.PSNR
+			q.VMAF += s.VMAF
+		}
+		n := float64(len(offsets))
+		q.SSIM, q.PSNR, q.VMAF = q.SSIM/n, q.PSNR/n, q.VMAF/n
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// qualityArguments builds ffmpeg arguments comparing t seconds of the rendition starting from ss against the source,
+// both scaled to the source display size w x h.
+func qualityArguments(input, rendition string, ss, t float64, w, h int, vmaf bool) []string {
+	start, dur := strconv.FormatFloat(ss, 'f', 3, 64), strconv.FormatFloat(t, 'f', 3, 64)
+	metrics := []string{"ssim", "psnr"}
+	if vmaf {
+		metrics = append(metrics, "libvmaf")
+	}
+	var dist, ref, cmp string
+	for i, m := range metrics {
+		dist += fmt.Sprintf("[d%d]", i)
+		ref += fmt.Sprintf("[r%d]", i)
+		cmp += fmt.Sprintf(";[d%d][r%d]%s", i, i, m)
+	}
+	prep := fmt.Sprintf("setpts=PTS-STARTPTS,scale=%d:%d:flags=bicubic,format=yuv420p,split=%d", w, h, len(metrics))
+	return []string{
+		"-hide_banner", "-nostdin", "-nostats", "-loglevel", "info",
+		"-ss", start, "-t", dur, "-i", rendition,
+		"-ss", start, "-t", dur, "-i", input,
+		"-lavfi", "[0:v]" + prep + dist + ";[1:v]" + prep + ref + cmp,
+		"-an", "-sn", "-f", "null", "-",
+	}
+}
+
+// parseQuality reads scores from summaries ffmpeg comparison filters log at the end of a run:
+//
+//	[Parsed_ssim_6 @ 0x5581] SSIM Y:0.981446 (17.314) U:0.989049 (19.605) V:0.987766 (19.124) All:0.984197 (18.012)
+//	[Parsed_psnr_7 @ 0x5582] PSNR y:40.117 u:45.201 v:44.987 average:41.379 min:38.902 max:44.011
+//	[Parsed_libvmaf_8 @ 0x5583] VMAF score: 93.184213
+func parseQuality(stderr string, vmaf bool) (Quality, error) {
+	q := Quality{}
+	m := reSSIM.FindStringSubmatch(stderr)
+	if m == nil {
+		return q, errors.New("no SSIM score in ffmpeg output")
+	}
+	q.SSIM, _ = strconv.ParseFloat(m[1], 64)
+	if m = rePSNR.FindStringSubmatch(stderr); m == nil {
+		return q, errors.New("no PSNR score in ffmpeg output")
+	}
+	q.PSNR = maxPSNR
+	if m[1] != "inf" {
+		q.PSNR, _ = strconv.ParseFloat(m[1], 64)
+		q.PSNR = math.Min(q.PSNR, maxPSNR)
+	}
+	if !vmaf {
+		return q, nil
+	}
+	if m = reVMAF.FindStringSubmatch(stderr); m == nil {
+		return q, errors.New("no VMAF score in ffmpeg output")
+	}
+	q.VMAF, _ = strconv.ParseFloat(m[1], 64)
+	return q, nil
+}
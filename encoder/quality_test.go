@@ -0,0 +1,139 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const stubQualityOutput = `[Parsed_ssim_6 @ 0x5581] SSIM Y:0.981446 (17.314) U:0.989049 (19.605) V:0.987766 (19.124) All:0.984197 (18.012)
+[Parsed_psnr_7 @ 0x5582] PSNR y:40.117 u:45.201 v:44.987 average:41.379 min:38.902 max:44.011
+[Parsed_libvmaf_8 @ 0x5583] VMAF score: 93.184213
+`
+
+func TestParseQuality(t *testing.T) {
+	q, err := parseQuality(stubQualityOutput, true)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.984197, q.SSIM, 1e-9)
+	assert.InDelta(t, 41.379, q.PSNR, 1e-9)
+	assert.InDelta(t, 93.184213, q.VMAF, 1e-9)
+
+	q, err = parseQuality("SSIM Y:1.000000 (inf) All:1.000000 (inf)\nPSNR y:inf u:inf v:inf average:inf min:inf max:inf\n", false)
+	require.NoError(t, err)
+	assert.InDelta(t, maxPSNR, q.PSNR, 1e-9)
+	assert.Zero(t, q.VMAF)
+
+	_, err = parseQuality("SSIM Y:0.98 All:0.98 (17.0)\nPSNR average:40.1 min:38\n", true)
+	assert.ErrorContains(t, err, "no VMAF score")
+	_, err = parseQuality("", false)
+	assert.ErrorContains(t, err, "no SSIM score")
+}
+
+func TestQualityArguments(t *testing.T) {
+	args := qualityArguments("in.mp4", "out/v1.m3u8", 12.5, 5, 1280, 720, false)
+	assert.Equal(t, []string{
+		"-hide_banner", "-nostdin", "-nostats", "-loglevel", "info",
+		"-ss", "12.500", "-t", "5.000", "-i", "out/v1.m3u8",
+		"-ss", "12.500", "-t", "5.000", "-i", "in.mp4",
+		"-lavfi", "[0:v]setpts=PTS-STARTPTS,scale=1280:720:flags=bicubic,format=yuv420p,split=2[d0][d1];" +
+			"[1:v]setpts=PTS-STARTPTS,scale=1280:720:flags=bicubic,format=yuv420p,split=2[r0][r1];" +
+			"[d0][r0]ssim;[d1][r1]psnr",
+		"-an", "-sn", "-f", "null", "-",
+	}, args)
+	assert.Contains(t, qualityArguments("in.mp4", "out/v1.m3u8", 0, 5, 1280, 720, true)[18], ";[d2][r2]libvmaf")
+}
+
+func TestEncodeQuality(t *testing.T) {
+	argsLog := path.Join(t.TempDir(), "args")
+	cfg := stubConfig(t, `case "$*" in
+  *-filters*)
+    echo " ... libvmaf           VV->V      Calculate the VMAF between two video streams."
+    exit 0 ;;
+  *-lavfi*)
+    echo "$@" >> `+argsLog+`
+    cat >&2 <<'EOF'
+`+stubQualityOutput+`EOF
+    exit 0 ;;
+esac
+`+fmt.Sprintf(stubChunkScript, path.Join(t.TempDir(), "encode")))
+	q := DefaultQualityConfig
+	e, err := NewEncoder(cfg.Quality(&q))
+	require.NoError(t, err)
+
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	res, err := e.Encode(in, path.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	waitProgress(t, res)
+	require.NoError(t, res.Err())
+
+	require.Len(t, res.Quality, len(res.Ladder.Tiers))
+	for i, tier := range res.Ladder.Tiers {
+		assert.Equal(t, tier.Definition, res.Quality[i].Definition)
+		assert.Equal(t, tier.CodecName(), res.Quality[i].Codec)
+	}
+	assert.InDelta(t, 0.984197, res.Quality[0].SSIM, 1e-9)
+	assert.InDelta(t, 93.184213, res.Quality[0].VMAF, 1e-9)
+
+	args, err := os.ReadFile(argsLog)
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "-ss 12.500 -t 5.000 -i "+path.Join(res.Output, "v0.m3u8"))
+	assert.Contains(t, string(args), "[d2][r2]libvmaf")
+}
+
+func TestEncodeQualityNoVMAF(t *testing.T) {
+	cfg := stubConfig(t, `case "$*" in
+  *-filters*) exit 0 ;;
+  *-lavfi*)
+    echo "SSIM Y:0.98 All:0.98 (17.0)" >&2
+    echo "PSNR average:40.1 min:38" >&2
+    exit 0 ;;
+esac
+`+fmt.Sprintf(stubChunkScript, path.Join(t.TempDir(), "encode")))
+	q := DefaultQualityConfig
+	e, err := NewEncoder(cfg.Quality(&q))
+	require.NoError(t, err)
+
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	res, err := e.Encode(in, path.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	waitProgress(t, res)
+	require.NoError(t, res.Err())
+	require.NotEmpty(t, res.Quality)
+	assert.InDelta(t, 40.1, res.Quality[0].PSNR, 1e-9)
+	assert.Zero(t, res.Quality[0].VMAF)
+}
+
+func TestEncodeQualitySourceTier(t *testing.T) {
+	ffprobe := path.Join(t.TempDir(), "ffprobe")
+	probeOutput := strings.Replace(stubProbeOutput, `"height": 720`, `"height": 600`, 1)
+	require.NoError(t, os.WriteFile(ffprobe, []byte("#!/bin/sh\ncat <<'EOF'\n"+probeOutput+"\nEOF\n"), 0700)) // #nosec G306
+	cfg := stubConfig(t, `case "$*" in
+  *-filters*) exit 0 ;;
+  *-lavfi*)
+    echo "SSIM Y:0.98 All:0.98 (17.0)" >&2
+    echo "PSNR average:40.1 min:38" >&2
+    exit 0 ;;
+esac
+`+fmt.Sprintf(stubChunkScript, path.Join(t.TempDir(), "encode")))
+	q := DefaultQualityConfig
+	e, err := NewEncoder(cfg.FfprobePath(ffprobe).Quality(&q))
+	require.NoError(t, err)
+
+	in := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+	res, err := e.Encode(in, path.Join(t.TempDir(), "out"))
+	require.NoError(t, err)
+	waitProgress(t, res)
+	require.NoError(t, res.Err())
+
+	require.Equal(t, 600, res.Ladder.Tiers[0].Height)
+	require.Len(t, res.Quality, len(res.Ladder.Tiers))
+	assert.EqualValues(t, "600p", res.Quality[0].Definition)
+}
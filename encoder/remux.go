@@ -0,0 +1,50 @@
+package encoder
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/pkg/errors"
+)
+
+// confirmRemux checks if source keyframes allow segmenting the top tier copied from the source,
+// re-encoding the tier otherwise. Copied segments can only be cut at source keyframes,
+// so these need to be no further apart than the target segment duration.
+func (e encoder) confirmRemux(ctx context.Context, input string, l *ladder.Ladder, ll logging.KVLogger) {
+	if len(l.Tiers) == 0 || !l.Tiers[0].Copy {
+		return
+	}
+	dur, _ := strconv.ParseFloat(l.Metadata.FMeta.GetFormat().GetDuration(), 64)
+	kfs, err := e.probeKeyframes(ctx, input)
+	if err == nil {
+		err = checkKeyframeGaps(kfs, dur, l.SegmentDuration())
+	}
+	if err != nil {
+		l.Tiers[0].Copy = false
+		ll.Info("source keyframes unsuitable for remuxing, re-encoding", "tier", l.Tiers[0].Definition, "reason", err)
+		return
+	}
+	ll.Info("remuxing source video", "tier", l.Tiers[0].Definition)
+}
+
+// checkKeyframeGaps returns an error if the video does not start with a keyframe
+// or has keyframes (or the end of the video) further apart than maxGap seconds.
+func checkKeyframeGaps(kfs []float64, duration, maxGap float64) error {
+	if len(kfs) == 0 || kfs[0] > 0.001 {
+		return errors.New("video does not start with a keyframe")
+	}
+	for i, kf := range kfs {
+		next := duration
+		if i+1 < len(kfs) {
+			next = kfs[i+1]
+		}
+		if next-kf > maxGap {
+			return fmt.Errorf("%.1fs between keyframes at %.1fs", next-kf, kf)
+		}
+	}
+	return nil
+}
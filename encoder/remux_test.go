@@ -0,0 +1,73 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckKeyframeGaps(t *testing.T) {
+	assert.NoError(t, checkKeyframeGaps([]float64{0, 4, 8}, 12, 4))
+	assert.ErrorContains(t, checkKeyframeGaps([]float64{0, 4, 10}, 12, 4), "6.0s between keyframes at 4.0s")
+	assert.ErrorContains(t, checkKeyframeGaps([]float64{0, 4, 8}, 20, 4), "12.0s between keyframes at 8.0s")
+	assert.ErrorContains(t, checkKeyframeGaps([]float64{0.5, 4}, 8, 4), "does not start with a keyframe")
+	assert.ErrorContains(t, checkKeyframeGaps(nil, 8, 4), "does not start with a keyframe")
+}
+
+// remuxStubEncoder returns stub encoder for an H.264 Main profile 720p source with keyframes every kfInterval seconds.
+func remuxStubEncoder(t *testing.T, script string, kfInterval int) Encoder {
+	t.Helper()
+	ffprobe := path.Join(t.TempDir(), "ffprobe")
+	probeOutput := strings.Replace(stubProbeOutput, `"codec_type": "video"`,
+		`"codec_type": "video", "codec_name": "h264", "profile": "Main"`, 1)
+	require.NoError(t, os.WriteFile(ffprobe, []byte(fmt.Sprintf(`#!/bin/sh
+case "$*" in
+  *show_entries*)
+    for t in $(seq 0 %d 59); do echo "packet,$t.000000,K__"; done
+    echo "format,0.000000" ;;
+  *)
+    cat <<'EOF'
+%s
+EOF
+    ;;
+esac
+`, kfInterval, probeOutput)), 0700)) // #nosec G306
+	e, err := NewEncoder(stubConfig(t, script).FfprobePath(ffprobe))
+	require.NoError(t, err)
+	return e
+}
+
+func TestEncodeRemux(t *testing.T) {
+	for _, tc := range []struct {
+		interval int
+		copied   bool
+	}{{2, true}, {12, false}} {
+		t.Run(fmt.Sprintf("keyframes every %ds", tc.interval), func(t *testing.T) {
+			argsLog := path.Join(t.TempDir(), "args")
+			e := remuxStubEncoder(t, fmt.Sprintf(stubChunkScript, argsLog), tc.interval)
+			in := path.Join(t.TempDir(), "in.mp4")
+			require.NoError(t, os.WriteFile(in, []byte("stub"), 0600))
+
+			res, err := e.Encode(in, path.Join(t.TempDir(), "out"))
+			require.NoError(t, err)
+			waitProgress(t, res)
+			require.NoError(t, res.Err())
+			assert.Equal(t, tc.copied, res.Ladder.Tiers[0].Copy)
+
+			args, err := os.ReadFile(argsLog)
+			require.NoError(t, err)
+			if tc.copied {
+				assert.Contains(t, string(args), "-map v:0 -c:v:0 copy")
+				assert.NotContains(t, string(args), "-filter:v:0")
+			} else {
+				assert.NotContains(t, string(args), "copy")
+				assert.Contains(t, string(args), "-filter:v:0 scale=-2:720")
+			}
+		})
+	}
+}
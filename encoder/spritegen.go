@@ -1,44 +1,159 @@
 package encoder
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
+	"path"
+	"strconv"
 	"strings"
 
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/pkg/errors"
 )
 
+// SpriteConfig defines how video thumbnails are laid out into sprite sheets.
+type SpriteConfig struct {
+	// Interval is the number of seconds between consecutive thumbnails.
+	Interval float64
+	// Width of a single thumbnail, its height follows the video display aspect ratio.
+	Width int
+	// Columns and Rows of thumbnails in a single sprite sheet.
+	Columns, Rows int
+	// Name is the base name of output files: <Name>.vtt thumbnail track and <Name>_<N>.jpg sprite sheets.
+	Name string
+}
+
+// DefaultSpriteConfig makes a thumbnail every two seconds, with 100 thumbnails per sheet.
+var DefaultSpriteConfig = SpriteConfig{
+	Interval: 2,
+	Width:    160,
+	Columns:  10,
+	Rows:     10,
+	Name:     "stream",
+}
+
+// SpriteGenerator makes sprite sheets of video thumbnails using ffmpeg fps, scale and tile filters,
+// along with a WebVTT track which maps video time ranges to thumbnail regions of the sheets.
 type SpriteGenerator struct {
-	cmdPath string
-	args    []string
-	log     logging.KVLogger
+	ffmpegPath string
+	cfg        SpriteConfig
+	log        logging.KVLogger
+}
+
+func NewSpriteGenerator(ffmpegPath string, cfg SpriteConfig, log logging.KVLogger) (*SpriteGenerator, error) {
+	if ffmpegPath == "" {
+		return nil, errors.New("ffmpeg binary path not set")
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &SpriteGenerator{ffmpegPath, cfg, log}, nil
 }
 
-// defaultArgs contains arguments to nodejs plus script args.
-var defaultArgs = []string{
-	"/usr/src/spritegen/cli.js",
-	"--interval", "2",
-	"--filename", "stream",
+func (c SpriteConfig) validate() error {
+	switch {
+	case c.Interval <= 0:
+		return fmt.Errorf("sprite interval must be positive, got %v", c.Interval)
+	case c.Width <= 0 || c.Width%2 != 0:
+		return fmt.Errorf("sprite thumbnail width must be positive and even, got %v", c.Width)
+	case c.Columns <= 0 || c.Rows <= 0:
+		return fmt.Errorf("sprite tile size must be positive, got %vx%v", c.Columns, c.Rows)
+	case c.Name == "":
+		return errors.New("sprite file name not set")
+	}
+	return nil
 }
 
-func NewSpriteGenerator(cmdPath string, log logging.KVLogger) (*SpriteGenerator, error) {
-	cmd := exec.Command(cmdPath, "-h")
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("unable to execute generator: %w", err)
+// thumbnailSize returns thumbnail dimensions for the video, keeping its display aspect ratio.
+func (c SpriteConfig) thumbnailSize(meta *ladder.Metadata) (int, int) {
+	w, h := meta.DisplaySize()
+	if w == 0 {
+		return c.Width, c.Width * 9 / 16
+	}
+	return c.Width, max(2, int(math.Round(float64(c.Width*h)/float64(w)/2))*2)
+}
+
+// sheetName returns file name of sprite sheet number n.
+func (c SpriteConfig) sheetName(n int) string {
+	return fmt.Sprintf("%s_%d.jpg", c.Name, n)
+}
+
+// arguments returns ffmpeg arguments producing sprite sheets from the input into output directory.
+func (c SpriteConfig) arguments(input, output string, tw, th int) []string {
+	filter := fmt.Sprintf("fps=1/%s,scale=%d:%d,setsar=1,tile=%dx%d",
+		strconv.FormatFloat(c.Interval, 'f', -1, 64), tw, th, c.Columns, c.Rows)
+	return []string{
+		"-hide_banner", "-nostdin", "-loglevel", "error", "-y",
+		"-i", input,
+		"-map", "0:v:0", "-an", "-sn",
+		"-filter:v", filter,
+		"-q:v", "5",
+		"-start_number", "0",
+		"-f", "image2",
+		path.Join(output, c.Name+"_%d.jpg"),
 	}
-	return &SpriteGenerator{cmdPath, defaultArgs, log}, nil
 }
 
-func (g SpriteGenerator) Generate(input, output string) error {
-	args := g.args
-	args = append(args, "--input", input, "--outputFolder", output)
-	g.log.Info("starting spritegen",
-		"cmd", g.cmdPath,
-		"args", strings.Join(args, " "),
-	)
-	_, err := exec.Command(g.cmdPath, args...).Output() // #nosec G204
-	if err != nil {
+// Generate writes sprite sheets and WebVTT thumbnail track for the input video into output directory.
+func (g SpriteGenerator) Generate(ctx context.Context, input, output string, meta *ladder.Metadata) error {
+	tw, th := g.cfg.thumbnailSize(meta)
+	args := g.cfg.arguments(input, output, tw, th)
+	g.log.Info("generating sprites", "args", strings.Join(args, " "))
+
+	var errb bytes.Buffer
+	cmd := exec.CommandContext(ctx, g.ffmpegPath, args...) // #nosec G204
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w (%s)", err, errb.String())
+	}
+
+	sheets := 0
+	for {
+		if _, err := os.Stat(path.Join(output, g.cfg.sheetName(sheets))); err != nil {
+			break
+		}
+		sheets++
+	}
+	if sheets == 0 {
+		return errors.New("ffmpeg produced no sprite sheets")
+	}
+	dur, _ := strconv.ParseFloat(meta.FMeta.GetFormat().GetDuration(), 64)
+	count := min(int(math.Ceil(dur/g.cfg.Interval)), sheets*g.cfg.Columns*g.cfg.Rows)
+	if err := writeSpriteTrack(output, g.cfg, count, tw, th, dur); err != nil {
 		return err
 	}
+	g.log.Info("sprites generated", "sheets", sheets, "thumbnails", count)
 	return nil
 }
+
+// writeSpriteTrack writes WebVTT track with a cue for each of count thumbnails, pointing at its region
+// of the sprite sheet with a media fragment (#xywh=x,y,w,h). The last cue ends at video duration.
+func writeSpriteTrack(dir string, cfg SpriteConfig, count, tw, th int, duration float64) error {
+	perSheet := cfg.Columns * cfg.Rows
+	b := &bytes.Buffer{}
+	b.WriteString(webvttHeader + "\n\n")
+	for i := 0; i < count; i++ {
+		start := float64(i) * cfg.Interval
+		end := math.Min(start+cfg.Interval, duration)
+		if i == count-1 {
+			end = math.Max(end, duration)
+		}
+		pos := i % perSheet
+		fmt.Fprintf(b, "%s --> %s\n%s#xywh=%d,%d,%d,%d\n\n",
+			formatVTTTimestamp(start), formatVTTTimestamp(end), cfg.sheetName(i/perSheet),
+			pos%cfg.Columns*tw, pos/cfg.Columns*th, tw, th)
+	}
+	return os.WriteFile(path.Join(dir, cfg.Name+".vtt"), b.Bytes(), 0644) // #nosec G306
+}
+
+// formatVTTTimestamp formats seconds as hh:mm:ss.ttt WebVTT timestamp.
+func formatVTTTimestamp(secs float64) string {
+	ms := int64(math.Round(secs * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
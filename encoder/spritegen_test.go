@@ -0,0 +1,108 @@
+package encoder
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSpriteGenerator(t *testing.T) {
+	_, err := NewSpriteGenerator("ffmpeg", DefaultSpriteConfig, logging.NoopKVLogger{})
+	require.NoError(t, err)
+
+	_, err = NewSpriteGenerator("", DefaultSpriteConfig, logging.NoopKVLogger{})
+	assert.Error(t, err)
+
+	for _, mod := range []func(*SpriteConfig){
+		func(c *SpriteConfig) { c.Interval = 0 },
+		func(c *SpriteConfig) { c.Width = 161 },
+		func(c *SpriteConfig) { c.Columns = 0 },
+		func(c *SpriteConfig) { c.Rows = -1 },
+		func(c *SpriteConfig) { c.Name = "" },
+	} {
+		cfg := DefaultSpriteConfig
+		mod(&cfg)
+		_, err = NewSpriteGenerator("ffmpeg", cfg, logging.NoopKVLogger{})
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
+
+func TestSpriteThumbnailSize(t *testing.T) {
+	cases := []struct {
+		w, h   int
+		tw, th int
+	}{
+		{1920, 1080, 160, 90},
+		{1080, 1920, 160, 284},
+		{640, 480, 160, 120},
+		{1000, 5, 160, 2},
+	}
+	for _, c := range cases {
+		m, err := ladder.WrapMeta(&ffmpeg.Metadata{
+			Streams: []ffmpeg.Streams{{CodecType: "video", Width: c.w, Height: c.h, AvgFrameRate: "30/1"}},
+		})
+		require.NoError(t, err)
+		tw, th := DefaultSpriteConfig.thumbnailSize(m)
+		assert.Equal(t, c.tw, tw, "%vx%v", c.w, c.h)
+		assert.Equal(t, c.th, th, "%vx%v", c.w, c.h)
+	}
+}
+
+func TestSpriteArguments(t *testing.T) {
+	cfg := DefaultSpriteConfig
+	cfg.Interval = 0.5
+	cfg.Columns, cfg.Rows = 5, 4
+	args := strings.Join(cfg.arguments("/in/video.mp4", "/out", 160, 90), " ")
+	assert.Contains(t, args, "-i /in/video.mp4 -map 0:v:0 -an -sn")
+	assert.Contains(t, args, "-filter:v fps=1/0.5,scale=160:90,setsar=1,tile=5x4")
+	assert.True(t, strings.HasSuffix(args, "-start_number 0 -f image2 /out/stream_%d.jpg"))
+}
+
+func TestWriteSpriteTrack(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DefaultSpriteConfig
+	cfg.Columns, cfg.Rows = 2, 2
+	require.NoError(t, writeSpriteTrack(dir, cfg, 6, 160, 90, 11.5))
+
+	cont, err := os.ReadFile(path.Join(dir, "stream.vtt"))
+	require.NoError(t, err)
+	assert.Equal(t, `WEBVTT
+
+00:00:00.000 --> 00:00:02.000
+stream_0.jpg#xywh=0,0,160,90
+
+00:00:02.000 --> 00:00:04.000
+stream_0.jpg#xywh=160,0,160,90
+
+00:00:04.000 --> 00:00:06.000
+stream_0.jpg#xywh=0,90,160,90
+
+00:00:06.000 --> 00:00:08.000
+stream_0.jpg#xywh=160,90,160,90
+
+00:00:08.000 --> 00:00:10.000
+stream_1.jpg#xywh=0,0,160,90
+
+00:00:10.000 --> 00:00:11.500
+stream_1.jpg#xywh=160,0,160,90
+
+`, string(cont))
+
+	cues, err := parseWebVTT(cont)
+	require.NoError(t, err)
+	assert.Len(t, cues, 6)
+}
+
+func TestFormatVTTTimestamp(t *testing.T) {
+	assert.Equal(t, "00:00:00.000", formatVTTTimestamp(0))
+	assert.Equal(t, "00:01:02.500", formatVTTTimestamp(62.5))
+	assert.Equal(t, "02:00:00.001", formatVTTTimestamp(7200.001))
+}
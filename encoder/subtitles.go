@@ -0,0 +1,70 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+)
+
+// mpegtsStartPTS is the timestamp (1.4s at 90kHz) ffmpeg MPEG-TS muxer starts video segments at.
+// WebVTT segments map their local time to it so players keep subtitles in sync.
+const mpegtsStartPTS = 126000
+
+// subtitleRendition is a WebVTT rendition produced from a source subtitle track.
+type subtitleRendition struct {
+	ladder.SubtitleTrack
+	URI string
+}
+
+// extractSubtitles converts text subtitle tracks of the source into segmented WebVTT renditions.
+// Tracks which fail to convert are skipped.
+func (e encoder) extractSubtitles(ctx context.Context, res *Result, ll logging.KVLogger) []subtitleRendition {
+	renditions := []subtitleRendition{}
+	tracks := res.Ladder.TextSubtitleTracks()
+	if len(tracks) == 0 {
+		return renditions
+	}
+
+	dur, _ := strconv.ParseFloat(res.OrigMeta.FMeta.GetFormat().GetDuration(), 64)
+	tsOffset := mpegtsStartPTS
+	if res.Ladder.FMP4() {
+		tsOffset = 0
+	}
+	for i, t := range tracks {
+		name := fmt.Sprintf("sub%d", i)
+		cues, err := e.convertSubtitles(ctx, res.Input, t.Index)
+		if err == nil {
+			err = segmentWebVTT(res.Output, name, cues, dur, res.Ladder.SegmentDuration(), tsOffset)
+		}
+		if err != nil {
+			ll.Warn("subtitle extraction failed", "track", t.Index, "codec", t.Codec, "err", err)
+			continue
+		}
+		renditions = append(renditions, subtitleRendition{SubtitleTrack: t, URI: name + ".m3u8"})
+	}
+	ll.Info("subtitles extracted", "count", len(renditions))
+	return renditions
+}
+
+// convertSubtitles converts source subtitle stream number n to WebVTT and parses its cues.
+func (e encoder) convertSubtitles(ctx context.Context, input string, n int) ([]vttCue, error) {
+	var outb, errb bytes.Buffer
+	args := []string{
+		"-hide_banner", "-nostdin", "-loglevel", "error",
+		"-i", input,
+		"-map", "0:s:" + strconv.Itoa(n),
+		"-c:s", "webvtt", "-f", "webvtt", "-",
+	}
+	cmd := exec.CommandContext(ctx, e.ffmpegPath, args...) // #nosec G204
+	cmd.Stdout = &outb
+	cmd.Stderr = &errb
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w (%s)", err, errb.String())
+	}
+	return parseWebVTT(outb.Bytes())
+}
@@ -0,0 +1,133 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const webvttHeader = "WEBVTT"
+
+type vttCue struct {
+	start, end float64
+	// block is the cue text as it appeared in the source, including identifier and timing lines.
+	block string
+}
+
+// parseWebVTT extracts cues from WebVTT document, skipping NOTE, STYLE and REGION blocks.
+func parseWebVTT(data []byte) ([]vttCue, error) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), []byte(webvttHeader)) {
+		return nil, errors.New("not a WebVTT document")
+	}
+
+	cues := []vttCue{}
+	blocks := strings.Split(string(data), "\n\n")
+	for _, b := range blocks[1:] {
+		b = strings.Trim(b, "\n")
+		if b == "" {
+			continue
+		}
+		lines := strings.Split(b, "\n")
+		ti := -1
+		for i, l := range lines[:min(2, len(lines))] {
+			if strings.Contains(l, "-->") {
+				ti = i
+				break
+			}
+		}
+		if ti < 0 {
+			continue
+		}
+		start, end, err := parseCueTiming(lines[ti])
+		if err != nil {
+			return nil, err
+		}
+		cues = append(cues, vttCue{start: start, end: end, block: b})
+	}
+	return cues, nil
+}
+
+func parseCueTiming(line string) (float64, float64, error) {
+	parts := strings.SplitN(line, "-->", 2)
+	start, err := parseVTTTimestamp(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	endFields := strings.Fields(parts[1])
+	if len(endFields) == 0 {
+		return 0, 0, fmt.Errorf("cue end time missing: %s", line)
+	}
+	end, err := parseVTTTimestamp(endFields[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+// parseVTTTimestamp parses hh:mm:ss.ttt and mm:ss.ttt timestamps into seconds.
+func parseVTTTimestamp(ts string) (float64, error) {
+	parts := strings.Split(ts, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("invalid timestamp: %s", ts)
+	}
+	var secs float64
+	for _, p := range parts[:len(parts)-1] {
+		v, err := strconv.Atoi(p)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timestamp: %s", ts)
+		}
+		secs = secs*60 + float64(v)
+	}
+	s, err := strconv.ParseFloat(parts[len(parts)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timestamp: %s", ts)
+	}
+	return secs*60 + s, nil
+}
+
+// segmentWebVTT splits cues into WebVTT segments of segDur seconds covering the whole video duration
+// and writes them along with a media playlist named <name>.m3u8 into dir.
+// Cues spanning a segment boundary are repeated in every segment they overlap, as HLS requires.
+// tsOffset is the MPEG-TS timestamp at which video segments start, used in X-TIMESTAMP-MAP.
+func segmentWebVTT(dir, name string, cues []vttCue, duration, segDur float64, tsOffset int) error {
+	for _, c := range cues {
+		duration = math.Max(duration, c.end)
+	}
+	count := max(1, int(math.Ceil(duration/segDur)))
+
+	pl := &bytes.Buffer{}
+	fmt.Fprintf(pl, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:%d\n", int(math.Ceil(segDur)))
+	pl.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n")
+
+	for i := 0; i < count; i++ {
+		segStart := float64(i) * segDur
+		segEnd := math.Min(segStart+segDur, duration)
+		if i == count-1 {
+			segEnd = duration
+		}
+
+		seg := &bytes.Buffer{}
+		fmt.Fprintf(seg, "%s\nX-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n\n", webvttHeader, tsOffset)
+		for _, c := range cues {
+			if c.start < segEnd && c.end > segStart {
+				fmt.Fprintf(seg, "%s\n\n", c.block)
+			}
+		}
+
+		segName := fmt.Sprintf("%s_s%06d.vtt", name, i)
+		if err := os.WriteFile(path.Join(dir, segName), seg.Bytes(), 0644); err != nil { // #nosec G306
+			return err
+		}
+		fmt.Fprintf(pl, "#EXTINF:%.6f,\n%s\n", segEnd-segStart, segName)
+	}
+	pl.WriteString("#EXT-X-ENDLIST\n")
+
+	return os.WriteFile(path.Join(dir, name+".m3u8"), pl.Bytes(), 0644) // #nosec G306
+}
@@ -0,0 +1,65 @@
+package encoder
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebVTT(t *testing.T) {
+	doc := "\xef\xbb\xbfWEBVTT\r\n\r\nSTYLE\r\n::cue { color: yellow }\r\n\r\n" +
+		"1\r\n00:00:01.000 --> 00:00:03.500\r\nHello\r\n\r\n" +
+		"NOTE skipped\r\n\r\n" +
+		"01:02.250 --> 01:04.000 align:start\r\nWorld\r\nsecond line\r\n"
+	cues, err := parseWebVTT([]byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, []vttCue{
+		{start: 1, end: 3.5, block: "1\n00:00:01.000 --> 00:00:03.500\nHello"},
+		{start: 62.25, end: 64, block: "01:02.250 --> 01:04.000 align:start\nWorld\nsecond line"},
+	}, cues)
+
+	_, err = parseWebVTT([]byte("1\n00:00:01,000 --> 00:00:03,500\nHello\n"))
+	require.Error(t, err)
+	_, err = parseWebVTT([]byte("WEBVTT\n\n00:01.x --> 00:03.000\nHello\n"))
+	require.Error(t, err)
+}
+
+func TestSegmentWebVTT(t *testing.T) {
+	dir := t.TempDir()
+	cues := []vttCue{
+		{start: 1, end: 2, block: "00:01.000 --> 00:02.000\nfirst"},
+		{start: 9, end: 11, block: "00:09.000 --> 00:11.000\nspanning"},
+	}
+	require.NoError(t, segmentWebVTT(dir, "sub0", cues, 25, 10, mpegtsStartPTS))
+
+	pl, err := os.ReadFile(path.Join(dir, "sub0.m3u8"))
+	require.NoError(t, err)
+	assert.Equal(t, `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:10.000000,
+sub0_s000000.vtt
+#EXTINF:10.000000,
+sub0_s000001.vtt
+#EXTINF:5.000000,
+sub0_s000002.vtt
+#EXT-X-ENDLIST
+`, string(pl))
+
+	header := "WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000\n\n"
+	expected := []string{
+		header + "00:01.000 --> 00:02.000\nfirst\n\n00:09.000 --> 00:11.000\nspanning\n\n",
+		header + "00:09.000 --> 00:11.000\nspanning\n\n",
+		header,
+	}
+	for i, e := range expected {
+		seg, err := os.ReadFile(path.Join(dir, []string{"sub0_s000000.vtt", "sub0_s000001.vtt", "sub0_s000002.vtt"}[i]))
+		require.NoError(t, err)
+		assert.Equal(t, e, string(seg))
+	}
+}
@@ -15,6 +15,10 @@ type ConductorConfig struct {
 	Redis         string
 	AdaptiveQueue AdaptiveQueue
 	Library       Library
+	Chunking      Chunking
+	// LadderProfiles is a directory with the same YAML ladder profiles workers have, channels can only be
+	// assigned profiles found there.
+	LadderProfiles string
 }
 
 type WorkerConfig struct {
@@ -22,6 +26,89 @@ type WorkerConfig struct {
 	Redis        string
 	EdgeToken    string
 	DiskPressure DiskPressure
+	// LadderProfiles is a directory with YAML ladder profiles which tasks can request by name.
+	LadderProfiles string
+	Sprites        Sprites
+	Poster         Poster
+	Preview        Preview
+	Chunks         Chunks
+	Quality        Quality
+	InputPolicy    InputPolicy
+}
+
+// Sprites configures thumbnail sprite sheets, zero values keep encoder defaults.
+type Sprites struct {
+	Disabled bool
+	// Interval is the number of seconds between thumbnails.
+	Interval float64
+	// Width of a single thumbnail.
+	Width int
+	// Columns and Rows of thumbnails in a sprite sheet.
+	Columns int
+	Rows    int
+}
+
+// Poster configures poster frame, zero values keep encoder defaults.
+type Poster struct {
+	Disabled bool
+	Width    int
+}
+
+// Preview configures animated preview clip, zero values keep encoder defaults.
+type Preview struct {
+	Enabled bool
+	// Format is webp or mp4.
+	Format    string
+	Duration  float64
+	Width     int
+	Framerate int
+}
+
+// Chunks configures parallel encoding of long videos in time chunks, zero values keep encoder defaults.
+type Chunks struct {
+	Enabled bool
+	// MinDuration is the video duration in seconds starting from which it is encoded in chunks.
+	MinDuration float64
+	// Duration is the target chunk duration in seconds.
+	Duration float64
+	// Parallel is the number of chunks encoded at the same time.
+	Parallel int
+}
+
+// Chunking configures splitting long videos into chunk tasks encoded by separate workers.
+type Chunking struct {
+	Enabled bool
+	// MinDuration is the video duration in seconds starting from which it is split.
+	MinDuration int
+	// Duration is the approximate chunk duration in seconds.
+	Duration int
+}
+
+// Quality configures rendition quality measurement against the source, zero values keep encoder defaults.
+type Quality struct {
+	Enabled bool
+	// Samples is the number of segments measured.
+	Samples int
+	// SampleDuration is the duration of a measured segment in seconds.
+	SampleDuration float64
+	// DisableVMAF limits measurement to SSIM and PSNR, which are much faster to compute.
+	DisableVMAF bool
+}
+
+// InputPolicy configures limits inputs are checked against before encoding, zero values keep encoder defaults.
+type InputPolicy struct {
+	Disabled bool
+	// MaxDuration is the maximum input duration in seconds.
+	MaxDuration float64
+	// MaxWidth and MaxHeight limit video resolution regardless of orientation.
+	MaxWidth     int
+	MaxHeight    int
+	MaxFramerate float64
+	// MaxBitrate is the maximum overall input bitrate in bits per second.
+	MaxBitrate int
+	// VideoCodecs and AudioCodecs list allowed ffprobe codec names, any codec is allowed if empty.
+	VideoCodecs []string
+	AudioCodecs []string
 }
 
 type DiskPressure struct {
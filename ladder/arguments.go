@@ -3,6 +3,7 @@ package ladder
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/shopspring/decimal"
 )
@@ -10,9 +11,12 @@ import (
 const (
 	MasterPlaylist     = "master.m3u8"
 	preset             = "veryfast"
-	videoCodec         = "libx264"
 	constantRateFactor = "26"
 	hlsTime            = "10"
+
+	hlsSegmentFilenameTS   = "v%v_s%06d.ts"
+	hlsSegmentFilenameFMP4 = "v%v_s%06d.m4s"
+	hlsInitFilenameFMP4    = "v%v_init.mp4"
 )
 
 const (
@@ -30,7 +34,7 @@ var hlsDefaultArguments = map[string]string{
 	"threads":              "2",
 	"preset":               preset,
 	"sc_threshold":         "0",
-	"c:v":                  "libx264",
+	"c:v":                  string(CodecH264),
 	"pix_fmt":              "yuv420p",
 	"f":                    "hls",
 	"hls_time":             hlsTime,
@@ -38,7 +42,7 @@ var hlsDefaultArguments = map[string]string{
 	"hls_flags":            "independent_segments",
 	"master_pl_name":       MasterPlaylist,
 	"strftime_mkdir":       "1",
-	"hls_segment_filename": "v%v_s%06d.ts",
+	"hls_segment_filename": hlsSegmentFilenameTS,
 }
 
 var hlsAudioArguments = map[string]string{
@@ -67,44 +71,121 @@ func (a *ArgumentSet) GetStrArguments() []string {
 		}
 	}
 
+	if a.Ladder.FMP4() {
+		args["hls_segment_type"] = "fmp4"
+		args["hls_segment_filename"] = hlsSegmentFilenameFMP4
+		args["hls_fmp4_init_filename"] = hlsInitFilenameFMP4
+	}
+
+	sharedAudio := a.Ladder.SharedAudio()
 	for n, tier := range a.Ladder.Tiers {
 		s := strconv.Itoa(n)
-		if a.Metadata.HasAudio {
+		switch {
+		case sharedAudio:
+			args[argVarStreamMap] += fmt.Sprintf("v:%s,agroup:%s ", s, AudioGroup)
+		case a.Metadata.HasAudio:
 			args[argVarStreamMap] += fmt.Sprintf("v:%s,a:%s ", s, s)
-		} else {
+		default:
 			args[argVarStreamMap] += fmt.Sprintf("v:%s ", s)
 		}
-		vRate := strconv.Itoa(tier.VideoBitrate)
-		ladArgs = append(ladArgs,
-			"-map", "v:0",
-			"-filter:v:"+s, "scale=-2:"+strconv.Itoa(tier.Height),
-			"-crf:v:"+s, strconv.Itoa(tier.CRF),
-			"-b:v:"+s, vRate,
-			"-maxrate:v:"+s, vRate,
-			"-bufsize:v:"+s, vRate,
-		)
-
-		switch {
-		case tier.KeepFramerate:
-			ladArgs = append(ladArgs,
-				"-g:v:"+s, strconv.Itoa(a.Metadata.FPS.Int()*2)) // nolint:goconst
-		case !tier.Framerate.IsZero():
-			ladArgs = append(ladArgs,
-				"-r:v:"+s, tier.Framerate.String(),
-				"-g:v:"+s, (tier.Framerate.Mul(decimal.NewFromInt(2)).String())) // nolint:goconst
-		default:
-			ladArgs = append(ladArgs,
-				"-r:v:"+s, a.Metadata.FPS.String(),
-				"-g:v:"+s, strconv.Itoa(a.Metadata.FPS.Int()*2)) // nolint:goconst
+		if tier.Copy {
+			ladArgs = append(ladArgs, "-map", "v:0", "-c:v:"+s, "copy")
+		} else {
+			ladArgs = append(ladArgs, a.videoArguments(tier, s)...)
 		}
-		if a.Metadata.HasAudio {
+		if a.Metadata.HasAudio && !sharedAudio {
 			ladArgs = append(ladArgs, "-map", "a:0", "-b:a:"+s, tier.AudioBitrate)
 		}
 	}
 
+	if sharedAudio || a.Ladder.IsAudioOnly() {
+		streamMap, mapArgs := a.audioArguments()
+		args[argVarStreamMap] += strings.Join(streamMap, " ")
+		ladArgs = append(ladArgs, mapArgs...)
+	}
+
 	for k, v := range args {
 		strArgs = append(strArgs, fmt.Sprintf("-%v", k), v)
 	}
 	strArgs = append(strArgs, ladArgs...)
 	return strArgs
 }
+
+// videoArguments sets encoding parameters of the tier video stream number s.
+func (a *ArgumentSet) videoArguments(tier Tier, s string) []string {
+	vRate := strconv.Itoa(tier.VideoBitrate)
+	args := []string{
+		"-map", "v:0",
+		"-filter:v:" + s, a.videoFilter(tier),
+		"-crf:v:" + s, strconv.Itoa(tier.CRF),
+		"-b:v:" + s, vRate,
+		"-maxrate:v:" + s, vRate,
+		"-bufsize:v:" + s, vRate,
+		"-c:v:" + s, string(tier.codec()),
+	}
+	for _, ca := range a.Ladder.codecArguments(tier.codec()) {
+		args = append(args, "-"+ca[0]+":v:"+s, ca[1])
+	}
+	args = append(args, a.colorArguments(tier, s)...)
+	return append(args, a.framerateArguments(tier, s)...)
+}
+
+// framerateArguments sets output frame rate and GOP size of two seconds for the tier.
+// Variable frame rate sources are always converted to constant frame rate
+// since GOP size is set in frames and would not match segment duration otherwise.
+func (a *ArgumentSet) framerateArguments(tier Tier, s string) []string {
+	switch {
+	case tier.KeepFramerate && !a.Metadata.VFR:
+		return []string{
+			"-g:v:" + s, strconv.Itoa(a.Metadata.FPS.Int() * 2)} // nolint:goconst
+	case !tier.Framerate.IsZero() && !tier.KeepFramerate:
+		return []string{
+			"-r:v:" + s, tier.Framerate.String(),
+			"-g:v:" + s, (tier.Framerate.Mul(decimal.NewFromInt(2)).String())} // nolint:goconst
+	default:
+		return []string{
+			"-r:v:" + s, a.Metadata.FPS.String(),
+			"-g:v:" + s, strconv.Itoa(a.Metadata.FPS.Int() * 2)} // nolint:goconst
+	}
+}
+
+// videoFilter scales the video to tier resolution, tone mapping HDR sources for SDR tiers.
+// Videos with non-square pixels are scaled to their display aspect ratio with square pixels.
+// Rotation needs no handling here since ffmpeg rotates frames before they get to the filter.
+func (a *ArgumentSet) videoFilter(tier Tier) string {
+	f := "scale=-2:" + strconv.Itoa(tier.Height)
+	if a.Metadata.Anamorphic() {
+		dw, dh := a.Metadata.DisplaySize()
+		f = fmt.Sprintf("scale=%d:%d,setsar=1", roundEven(float64(tier.Height*dw)/float64(dh)), tier.Height)
+	}
+	if a.Metadata.Color.IsHDR() && !tier.HDR {
+		f += "," + a.Metadata.Color.tonemapFilter()
+	}
+	return f
+}
+
+// colorArguments sets pixel format and color tags for tiers of HDR sources.
+// Output of SDR sources is left as is.
+func (a *ArgumentSet) colorArguments(tier Tier, s string) []string {
+	c := a.Metadata.Color
+	if !c.IsHDR() {
+		return nil
+	}
+	if !tier.HDR {
+		return []string{
+			"-color_primaries:v:" + s, "bt709",
+			"-color_trc:v:" + s, "bt709",
+			"-colorspace:v:" + s, "bt709",
+		}
+	}
+	args := []string{
+		"-pix_fmt:v:" + s, hdrPixFmt,
+		"-color_primaries:v:" + s, c.hdrPrimaries(),
+		"-color_trc:v:" + s, c.Transfer,
+		"-colorspace:v:" + s, c.hdrSpace(),
+	}
+	for _, ca := range hdrCodecArguments[tier.codec()] {
+		args = append(args, "-"+ca[0]+":v:"+s, ca[1])
+	}
+	return args
+}
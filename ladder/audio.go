@@ -0,0 +1,126 @@
+package ladder
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/floostack/transcoder/ffmpeg"
+)
+
+// AudioGroup is the name of HLS audio rendition group shared by video variants.
+// ffmpeg prefixes it with "group_" in the master playlist.
+const AudioGroup = "audio"
+
+// Audio configures how audio is packaged into the HLS output.
+type Audio struct {
+	// Shared makes audio a separate rendition group (EXT-X-MEDIA TYPE=AUDIO) which all video variants reference,
+	// instead of muxing a copy of audio into every variant.
+	Shared bool
+	// Bitrate of the shared audio rendition. Audio bitrate of the top tier is used if not set.
+	Bitrate string `yaml:",omitempty"`
+	// Tiers are audio bitrates of variants produced for sources without a video stream.
+	Tiers []string `yaml:",flow"`
+}
+
+// AudioTrack is a source audio stream, each of them becomes a separate rendition in the shared audio group.
+type AudioTrack struct {
+	// Index is the number of the stream among source audio streams, as in ffmpeg "a:N" stream specifier.
+	Index    int
+	Language string
+	Title    string
+	// Default is set from the stream default disposition.
+	Default bool
+	// Commentary is set for commentary and audio description streams, which should not be selected automatically.
+	Commentary bool
+}
+
+func audioTracks(fmeta *ffmpeg.Metadata) []AudioTrack {
+	tracks := []AudioTrack{}
+	for _, s := range fmeta.GetStreams() {
+		if s.GetCodecType() != "audio" {
+			continue
+		}
+		d := s.GetDisposition()
+		tracks = append(tracks, AudioTrack{
+			Index:      len(tracks),
+			Default:    d.GetDefault() == 1,
+			Commentary: d.GetComment() == 1 || d.GetVisualImpaired() == 1,
+		})
+	}
+	return tracks
+}
+
+// AudioTracks returns source audio tracks to be encoded. Streams without track information get a single track.
+func (x Ladder) AudioTracks() []AudioTrack {
+	if x.Metadata == nil || len(x.Metadata.AudioTracks) == 0 {
+		return []AudioTrack{{Default: true}}
+	}
+	return x.Metadata.AudioTracks
+}
+
+// DefaultAudioTrack returns position of the track which should be played by default:
+// the first one with default disposition, or the first track if none has it.
+func (x Ladder) DefaultAudioTrack() int {
+	for i, t := range x.AudioTracks() {
+		if t.Default {
+			return i
+		}
+	}
+	return 0
+}
+
+// IsAudioOnly tells if the ladder was built for a source without a video stream.
+func (x Ladder) IsAudioOnly() bool {
+	return x.Metadata != nil && !x.Metadata.HasVideo() && x.Metadata.HasAudio
+}
+
+// SharedAudio tells if audio should be encoded as a separate rendition group.
+// Sources with multiple audio tracks and DASH ladders, which cannot have audio muxed into video segments, always get one.
+func (x Ladder) SharedAudio() bool {
+	if len(x.Tiers) == 0 || x.Metadata == nil || !x.Metadata.HasAudio {
+		return false
+	}
+	return x.Audio.Shared || x.DASH || len(x.Metadata.AudioTracks) > 1
+}
+
+// tweakAudioOnly builds a ladder for a source which only has an audio stream.
+func (x Ladder) tweakAudioOnly(md *Metadata) (Ladder, error) {
+	if len(x.Audio.Tiers) == 0 {
+		return x, fmt.Errorf("ladder has no audio tiers for audio-only source")
+	}
+	return Ladder{
+		Args:        x.Args,
+		Tiers:       []Tier{},
+		Metadata:    md,
+		Audio:       x.Audio,
+		SegmentType: x.SegmentType,
+		DASH:        x.DASH,
+	}, nil
+}
+
+// audioArguments returns ffmpeg stream mapping for audio-only ladder and shared audio renditions.
+// Audio variants are numbered after video variants, so their playlists are named
+// v<len(Tiers)>.m3u8 and onwards.
+// Audio-only ladders are made from the default audio track, shared groups get a rendition for every track.
+func (a *ArgumentSet) audioArguments() (streamMap []string, mapArgs []string) {
+	tracks := a.Ladder.AudioTracks()
+	if a.Ladder.IsAudioOnly() {
+		src := "a:" + strconv.Itoa(tracks[a.Ladder.DefaultAudioTrack()].Index)
+		for n, br := range a.Ladder.Audio.Tiers {
+			s := strconv.Itoa(n)
+			streamMap = append(streamMap, "a:"+s)
+			mapArgs = append(mapArgs, "-map", src, "-b:a:"+s, br)
+		}
+		return streamMap, mapArgs
+	}
+
+	for n, t := range tracks {
+		s := strconv.Itoa(n)
+		streamMap = append(streamMap, "a:"+s+",agroup:"+AudioGroup)
+		mapArgs = append(mapArgs, "-map", "a:"+strconv.Itoa(t.Index), "-b:a:"+s, a.Ladder.Audio.Bitrate)
+		if t.Language != "" {
+			mapArgs = append(mapArgs, "-metadata:s:a:"+s, "language="+t.Language)
+		}
+	}
+	return streamMap, mapArgs
+}
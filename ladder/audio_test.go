@@ -0,0 +1,138 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTweakSharedAudio(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	l, err := Load(defaultLadderYaml)
+	require.NoError(err)
+	l.Audio.Shared = true
+
+	meta := generateMeta(1280, 720, 5000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+
+	newLadder, err := l.Tweak(m)
+	require.NoError(err)
+	require.Len(newLadder.Tiers, 3)
+	assert.True(newLadder.SharedAudio())
+	assert.False(newLadder.IsAudioOnly())
+	assert.Equal("128k", newLadder.Audio.Bitrate, "top tier audio bitrate should be used by default")
+
+	args := strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-var_stream_map v:0,agroup:audio v:1,agroup:audio v:2,agroup:audio a:0,agroup:audio")
+	assert.Contains(args, "-map a:0 -b:a:0 128k")
+	assert.NotContains(args, "-b:a:1")
+	assert.Contains(args, "-c:a aac")
+
+	l.Audio.Bitrate = "192k"
+	newLadder, err = l.Tweak(m)
+	require.NoError(err)
+	args = strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-map a:0 -b:a:0 192k")
+
+	meta = generateMetaVideoOnly(1280, 720, 5000, "30/1")
+	m, err = WrapMeta(&meta)
+	require.NoError(err)
+	newLadder, err = l.Tweak(m)
+	require.NoError(err)
+	assert.False(newLadder.SharedAudio())
+	args = strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.NotContains(args, "agroup")
+	assert.NotContains(args, "-map a:0")
+}
+
+func TestTweakAudioOnly(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	meta := ffmpeg.Metadata{
+		Format: ffmpeg.Format{BitRate: "320000", Duration: "1800.0"},
+		Streams: []ffmpeg.Streams{
+			{CodecType: "audio", CodecName: "mp3", BitRate: "320000"},
+			{CodecType: "video", CodecName: "mjpeg", Width: 600, Height: 600, AvgFrameRate: "0/0"},
+		},
+	}
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	assert.True(m.HasAudio)
+	assert.False(m.HasVideo(), "cover art should not be treated as video")
+	assert.Nil(m.FPS)
+
+	newLadder, err := Default.Tweak(m)
+	require.NoError(err)
+	assert.True(newLadder.IsAudioOnly())
+	assert.Empty(newLadder.Tiers)
+	assert.Empty(newLadder.CodecNames())
+
+	args := strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-var_stream_map a:0 a:1 a:2")
+	assert.Contains(args, "-map a:0 -b:a:0 160k -map a:0 -b:a:1 96k -map a:0 -b:a:2 64k")
+	assert.NotContains(args, "-map v:0")
+
+	noAudioTiers := Default
+	noAudioTiers.Audio.Tiers = nil
+	_, err = noAudioTiers.Tweak(m)
+	assert.ErrorContains(err, "no audio tiers")
+
+	_, err = WrapMeta(&ffmpeg.Metadata{Streams: []ffmpeg.Streams{{CodecType: "data"}}})
+	assert.ErrorContains(err, "no video or audio stream found")
+}
+
+func TestTweakAudioTracks(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	meta := ffmpeg.Metadata{
+		Format: ffmpeg.Format{BitRate: "5000000"},
+		Streams: []ffmpeg.Streams{
+			{CodecType: "video", BitRate: "5000000", Width: 1280, Height: 720, AvgFrameRate: "30/1"},
+			{CodecType: "audio", Disposition: ffmpeg.Disposition{Default: 0}},
+			{CodecType: "subtitle"},
+			{CodecType: "audio", Disposition: ffmpeg.Disposition{Default: 1}},
+			{CodecType: "audio", Disposition: ffmpeg.Disposition{Comment: 1}},
+		},
+	}
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	require.NoError(m.ReadStreamTags([]byte(`{"streams": [
+		{"codec_type": "video", "tags": {"language": "eng"}},
+		{"codec_type": "audio", "tags": {"language": "eng", "title": "Original "}},
+		{"codec_type": "subtitle", "tags": {"language": "fre"}},
+		{"codec_type": "audio", "tags": {"LANGUAGE": "ger"}},
+		{"codec_type": "audio", "tags": {"language": "und", "title": "Director's commentary"}}
+	]}`)))
+	assert.Equal([]AudioTrack{
+		{Index: 0, Language: "en", Title: "Original"},
+		{Index: 1, Language: "de", Default: true},
+		{Index: 2, Title: "Director's commentary", Commentary: true},
+	}, m.AudioTracks)
+
+	newLadder, err := Default.Tweak(m)
+	require.NoError(err)
+	assert.True(newLadder.SharedAudio(), "multiple audio tracks should always be shared")
+	assert.Equal(1, newLadder.DefaultAudioTrack())
+
+	args := strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-var_stream_map v:0,agroup:audio v:1,agroup:audio v:2,agroup:audio a:0,agroup:audio a:1,agroup:audio a:2,agroup:audio")
+	assert.Contains(args,
+		"-map a:0 -b:a:0 128k -metadata:s:a:0 language=en -map a:1 -b:a:1 128k -metadata:s:a:1 language=de -map a:2 -b:a:2 128k")
+
+	meta.Streams = meta.Streams[1:]
+	m, err = WrapMeta(&meta)
+	require.NoError(err)
+	newLadder, err = Default.Tweak(m)
+	require.NoError(err)
+	require.True(newLadder.IsAudioOnly())
+	args = strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-map a:1 -b:a:0 160k -map a:1 -b:a:1 96k", "audio-only ladder should use the default track")
+}
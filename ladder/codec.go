@@ -0,0 +1,148 @@
+package ladder
+
+import "fmt"
+
+// Codec is an ffmpeg video encoder name which can be set for a ladder tier.
+type Codec string
+
+const (
+	CodecH264 Codec = "libx264"
+	CodecHEVC Codec = "libx265"
+	CodecAV1  Codec = "libsvtav1"
+
+	// AudioCodecTag is the RFC 6381 codec string for AAC-LC audio we produce.
+	AudioCodecTag = "mp4a.40.2"
+
+	// defaultH264Profile is the H.264 profile tiers are encoded with unless the ladder sets profile:v argument.
+	// It is always set explicitly since libx264 picks the profile by input otherwise.
+	defaultH264Profile = "main"
+)
+
+// h264Profiles are H.264 profiles tiers can be encoded with, each one a superset of the previous.
+// probeName is how ffprobe reports the profile, tag is profile_idc and constraint flags part of avc1 codec strings.
+var h264Profiles = []struct{ name, probeName, tag string }{
+	{"baseline", "Constrained Baseline", "42E0"},
+	{"main", "Main", "4D40"},
+	{"high", "High", "6400"},
+}
+
+// h264ProfileRank returns the position of the profile in h264Profiles by its libx264 or ffprobe name,
+// -1 for profiles not there.
+func h264ProfileRank(name string) int {
+	for i, p := range h264Profiles {
+		if name == p.name || name == p.probeName {
+			return i
+		}
+	}
+	return -1
+}
+
+type codecLevel struct {
+	id            int
+	maxPicSize    int64
+	maxSampleRate int64
+}
+
+// Level limits are expressed in luma samples per picture and luma samples per second.
+var (
+	h264Levels = []codecLevel{
+		{0x0D, 396 * 256, 11880 * 256},
+		{0x15, 792 * 256, 19800 * 256},
+		{0x1E, 1620 * 256, 40500 * 256},
+		{0x1F, 3600 * 256, 108000 * 256},
+		{0x20, 5120 * 256, 216000 * 256},
+		{0x28, 8192 * 256, 245760 * 256},
+		{0x2A, 8704 * 256, 522240 * 256},
+		{0x32, 22080 * 256, 589824 * 256},
+		{0x33, 36864 * 256, 983040 * 256},
+		{0x34, 36864 * 256, 2073600 * 256},
+	}
+	hevcLevels = []codecLevel{
+		{60, 122880, 3686400},
+		{63, 245760, 7372800},
+		{90, 552960, 16588800},
+		{93, 983040, 33177600},
+		{120, 2228224, 66846720},
+		{123, 2228224, 133693440},
+		{150, 8912896, 267386880},
+		{153, 8912896, 534773760},
+		{156, 8912896, 1069547520},
+	}
+	av1Levels = []codecLevel{
+		{0, 147456, 4423680},
+		{1, 278784, 8363520},
+		{4, 665856, 19975680},
+		{5, 1065024, 31950720},
+		{8, 2359296, 70778880},
+		{9, 2359296, 141557760},
+		{12, 8912896, 267386880},
+		{13, 8912896, 534773760},
+		{14, 8912896, 1069547520},
+	}
+)
+
+// codecArguments contains per-stream encoder options, in the order they should be supplied to ffmpeg.
+// Generic options (crf, bitrate, gop size) are set for every tier regardless of the codec.
+// H.264 profile depends on the ladder and is added by Ladder.codecArguments.
+var codecArguments = map[Codec][][2]string{
+	CodecH264: {},
+	CodecHEVC: {
+		{"preset", preset},
+		{"tag", "hvc1"},
+		{"x265-params", "log-level=error"},
+	},
+	CodecAV1: {
+		{"preset", "8"},
+	},
+}
+
+// needsFMP4 tells if the codec cannot be reliably carried in MPEG-TS HLS segments.
+func (c Codec) needsFMP4() bool {
+	return c == CodecHEVC || c == CodecAV1
+}
+
+// Name returns a short codec family name, independent of the encoder used.
+func (c Codec) Name() string {
+	switch c {
+	case CodecH264:
+		return "h264"
+	case CodecHEVC:
+		return "hevc"
+	case CodecAV1:
+		return "av1"
+	default:
+		return string(c)
+	}
+}
+
+// Tag returns RFC 6381 codec string (as used in HLS CODECS attribute) for a video of given dimensions and frame rate.
+// 8-bit output is assumed (in defaultH264Profile for H.264, Main for other codecs), HDR tiers are described by tag.
+func (c Codec) Tag(width, height int, fps float64) string {
+	return c.tag(width, height, fps, 8, defaultH264Profile)
+}
+
+// tag returns codec string for the output of given bit depth and H.264 profile. H.264 output is always 8-bit.
+func (c Codec) tag(width, height int, fps float64, depth int, h264Profile string) string {
+	picSize := int64(width) * int64(height)
+	sampleRate := int64(float64(picSize) * fps)
+	switch c {
+	case CodecHEVC:
+		if depth > 8 {
+			return fmt.Sprintf("hvc1.2.4.L%d.B0", pickLevel(hevcLevels, picSize, sampleRate))
+		}
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", pickLevel(hevcLevels, picSize, sampleRate))
+	case CodecAV1:
+		return fmt.Sprintf("av01.0.%02dM.%02d", pickLevel(av1Levels, picSize, sampleRate), depth)
+	default:
+		return fmt.Sprintf("avc1.%s%02X", h264Profiles[h264ProfileRank(h264Profile)].tag, pickLevel(h264Levels, picSize, sampleRate))
+	}
+}
+
+func pickLevel(levels []codecLevel, picSize, sampleRate int64) int {
+	for _, l := range levels {
+		if picSize <= l.maxPicSize && sampleRate <= l.maxSampleRate {
+			return l.id
+		}
+	}
+	return levels[len(levels)-1].id
+}
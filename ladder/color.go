@@ -0,0 +1,116 @@
+package ladder
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// TransferPQ is the ffmpeg name of SMPTE ST 2084 (HDR10) transfer characteristics.
+	TransferPQ = "smpte2084"
+	// TransferHLG is the ffmpeg name of Hybrid Log-Gamma transfer characteristics.
+	TransferHLG = "arib-std-b67"
+
+	sdrPixFmt = "yuv420p"
+	hdrPixFmt = "yuv420p10le"
+)
+
+var pixFmtDepthRe = regexp.MustCompile(`p(\d+)(le|be)$`)
+
+// hdrCodecArguments contains per-stream encoder options for tiers preserving HDR.
+// Only codecs listed here can produce HDR tiers.
+var hdrCodecArguments = map[Codec][][2]string{
+	CodecHEVC: {
+		{"profile", "main10"},
+	},
+	CodecAV1: {},
+}
+
+// Color describes color properties of the source video stream.
+type Color struct {
+	Transfer  string
+	Primaries string
+	Space     string
+	BitDepth  int
+}
+
+// IsHDR tells if the video uses one of HDR transfer functions.
+func (c Color) IsHDR() bool {
+	return c.Transfer == TransferPQ || c.Transfer == TransferHLG
+}
+
+// VideoRange returns HLS VIDEO-RANGE attribute value for the video.
+func (c Color) VideoRange() string {
+	switch c.Transfer {
+	case TransferPQ:
+		return "PQ"
+	case TransferHLG:
+		return "HLG"
+	default:
+		return "SDR"
+	}
+}
+
+// pixFmtDepth returns bit depth of ffmpeg pixel format, assuming 8 bits when it is not spelled out.
+func pixFmtDepth(pixFmt string) int {
+	m := pixFmtDepthRe.FindStringSubmatch(pixFmt)
+	if m == nil {
+		return 8
+	}
+	d, _ := strconv.Atoi(m[1])
+	return d
+}
+
+// ReadColor fills video color properties from ffprobe JSON output, since ffmpeg.Metadata does not carry them.
+func (m *Metadata) ReadColor(ffprobeJSON []byte) error {
+	if !m.HasVideo() {
+		return nil
+	}
+	var probe struct {
+		Streams []struct {
+			Index          int    `json:"index"`
+			ColorTransfer  string `json:"color_transfer"`
+			ColorPrimaries string `json:"color_primaries"`
+			ColorSpace     string `json:"color_space"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(ffprobeJSON, &probe); err != nil {
+		return err
+	}
+	for _, s := range probe.Streams {
+		if s.Index != m.VideoStream.GetIndex() {
+			continue
+		}
+		m.Color.Transfer = s.ColorTransfer
+		m.Color.Primaries = s.ColorPrimaries
+		m.Color.Space = s.ColorSpace
+		return nil
+	}
+	return fmt.Errorf("video stream %d not found in ffprobe output", m.VideoStream.GetIndex())
+}
+
+// hdrPrimaries returns color primaries of HDR video, assuming BT.2020 when the source does not specify them.
+func (c Color) hdrPrimaries() string {
+	if c.Primaries == "" || c.Primaries == "unknown" {
+		return "bt2020"
+	}
+	return c.Primaries
+}
+
+// hdrSpace returns color matrix of HDR video, assuming BT.2020 non-constant luminance when the source does not specify it.
+func (c Color) hdrSpace() string {
+	if c.Space == "" || c.Space == "unknown" {
+		return "bt2020nc"
+	}
+	return c.Space
+}
+
+// tonemapFilter is an ffmpeg filter chain converting HDR video to SDR BT.709.
+func (c Color) tonemapFilter() string {
+	return fmt.Sprintf(
+		"zscale=tin=%s:pin=%s:min=%s:t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,"+
+			"tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=%s",
+		c.Transfer, c.hdrPrimaries(), c.hdrSpace(), sdrPixFmt)
+}
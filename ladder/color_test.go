@@ -0,0 +1,82 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadColor(t *testing.T) {
+	meta := generateMeta(3840, 2160, 20000, "24/1")
+	meta.Streams[1].Index = 1
+	meta.Streams[1].PixFmt = "yuv420p10le"
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	require.NoError(t, m.ReadColor([]byte(`{"streams": [
+		{"index": 0, "codec_type": "audio"},
+		{"index": 1, "codec_type": "video", "color_transfer": "smpte2084", "color_primaries": "bt2020", "color_space": "bt2020nc"}
+	]}`)))
+	assert.Equal(t, Color{Transfer: TransferPQ, Primaries: "bt2020", Space: "bt2020nc", BitDepth: 10}, m.Color)
+	assert.True(t, m.Color.IsHDR())
+	assert.Equal(t, "PQ", m.Color.VideoRange())
+
+	assert.Error(t, m.ReadColor([]byte(`{"streams": []}`)))
+	assert.Equal(t, 8, pixFmtDepth("yuv420p"))
+	assert.Equal(t, 12, pixFmtDepth("yuv444p12be"))
+}
+
+func TestTweakHDR(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	l, err := Load([]byte(`
+tiers:
+  - definition: 1080p
+    bitrate: 3500_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+  - definition: 720p
+    bitrate: 2500_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+  - definition: 1080p-hdr
+    bitrate: 4500_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libx265
+    hdr: true
+`))
+	require.NoError(err)
+
+	meta := generateMeta(1920, 1080, 8000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	sdrLadder, err := l.Tweak(m)
+	require.NoError(err)
+	require.Len(sdrLadder.Tiers, 2, "HDR tiers should be skipped for SDR sources")
+	args := strings.Join(sdrLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.NotContains(args, "tonemap")
+	assert.NotContains(args, "-color_trc")
+
+	m.Color = Color{Transfer: TransferHLG, BitDepth: 10}
+	hdrLadder, err := l.Tweak(m)
+	require.NoError(err)
+	require.Len(hdrLadder.Tiers, 3)
+	assert.False(hdrLadder.Tiers[0].HDR)
+	assert.True(hdrLadder.Tiers[2].HDR)
+	assert.Equal("hvc1.2.4.L120.B0", hdrLadder.VideoCodecTag(2))
+
+	args = strings.Join(hdrLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args,
+		"-filter:v:0 scale=-2:1080,zscale=tin=arib-std-b67:pin=bt2020:min=bt2020nc:t=linear:npl=100,format=gbrpf32le,"+
+			"zscale=p=bt709,tonemap=tonemap=hable:desat=0,zscale=t=bt709:m=bt709:r=tv,format=yuv420p ")
+	assert.Contains(args, "-color_primaries:v:1 bt709 -color_trc:v:1 bt709 -colorspace:v:1 bt709")
+	assert.Contains(args, "-filter:v:2 scale=-2:1080 ")
+	assert.Contains(args,
+		"-pix_fmt:v:2 yuv420p10le -color_primaries:v:2 bt2020 -color_trc:v:2 arib-std-b67 -colorspace:v:2 bt2020nc -profile:v:2 main10")
+}
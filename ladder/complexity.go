@@ -0,0 +1,62 @@
+package ladder
+
+import (
+	"math"
+)
+
+const (
+	// ProbeHeight is the height sample encodes are scaled to during complexity probe.
+	ProbeHeight = 360
+	// ProbeCRF is the constant rate factor sample encodes are made with.
+	ProbeCRF = 23
+
+	maxCRFShift = 2
+)
+
+// ComplexityProbe configures content-aware tuning of the ladder.
+// When enabled, a fast test encode of sampled segments is made before the actual encoding
+// and its bitrate is compared to ReferenceBitrate, which is a bitrate typical content yields at ProbeCRF.
+type ComplexityProbe struct {
+	Enabled          bool
+	ReferenceBitrate int     `yaml:"reference_bitrate"`
+	MinFactor        float64 `yaml:"min_factor"`
+	MaxFactor        float64 `yaml:"max_factor"`
+}
+
+// Complexity is a result of the complexity probe.
+type Complexity struct {
+	// SampleBitrate is an average bitrate of sample encodes.
+	SampleBitrate int
+	// Factor is a multiplier for tier bitrates, below 1 for simple content and above 1 for complex content.
+	Factor float64
+}
+
+// Evaluate calculates complexity factor for a sample bitrate obtained during complexity probe.
+func (p ComplexityProbe) Evaluate(sampleBitrate int) *Complexity {
+	c := &Complexity{SampleBitrate: sampleBitrate, Factor: 1}
+	if p.ReferenceBitrate <= 0 || sampleBitrate <= 0 {
+		return c
+	}
+	c.Factor = float64(sampleBitrate) / float64(p.ReferenceBitrate)
+	if p.MinFactor > 0 && c.Factor < p.MinFactor {
+		c.Factor = p.MinFactor
+	}
+	if p.MaxFactor > 0 && c.Factor > p.MaxFactor {
+		c.Factor = p.MaxFactor
+	}
+	c.Factor = math.Round(c.Factor*100) / 100
+	return c
+}
+
+// apply scales tier bitrate by complexity factor and shifts its CRF by up to maxCRFShift points,
+// decreasing it for complex content and increasing for simple content.
+func (c *Complexity) apply(t Tier) Tier {
+	if c == nil || c.Factor <= 0 || c.Factor == 1 {
+		return t
+	}
+	t.VideoBitrate = int(math.Round(float64(t.VideoBitrate) * c.Factor))
+	shift := int(math.Round(-3 * math.Log2(c.Factor)))
+	shift = max(-maxCRFShift, min(maxCRFShift, shift))
+	t.CRF += shift
+	return t
+}
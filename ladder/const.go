@@ -4,6 +4,4 @@ const (
 	D1080p Definition = "1080p"
 	D720p  Definition = "720p"
 	D144p  Definition = "144p"
-
-	nsRateFactor = 0.37
 )
@@ -0,0 +1,102 @@
+package ladder
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+var aspectRatioRe = regexp.MustCompile(`^(\d+):(\d+)$`)
+
+// ReadRotation fills video rotation from display matrix side data or legacy rotate tag in ffprobe JSON output,
+// since ffmpeg.Metadata does not carry either.
+func (m *Metadata) ReadRotation(ffprobeJSON []byte) error {
+	if !m.HasVideo() {
+		return nil
+	}
+	var probe struct {
+		Streams []struct {
+			Index        int               `json:"index"`
+			Tags         map[string]string `json:"tags"`
+			SideDataList []struct {
+				Rotation float64 `json:"rotation"`
+			} `json:"side_data_list"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(ffprobeJSON, &probe); err != nil {
+		return err
+	}
+	for _, s := range probe.Streams {
+		if s.Index != m.VideoStream.GetIndex() {
+			continue
+		}
+		// Display matrix rotation is counterclockwise while the rotate tag is clockwise.
+		var rot float64
+		for _, sd := range s.SideDataList {
+			if sd.Rotation != 0 {
+				rot = -sd.Rotation
+			}
+		}
+		if r, err := strconv.ParseFloat(s.Tags["rotate"], 64); err == nil && rot == 0 {
+			rot = r
+		}
+		m.Rotation = normalizeRotation(rot)
+	}
+	return nil
+}
+
+// normalizeRotation rounds rotation to the nearest multiple of 90 degrees in 0..270 range.
+func normalizeRotation(deg float64) int {
+	r := int(math.Round(deg/90)) * 90 % 360
+	if r < 0 {
+		r += 360
+	}
+	return r
+}
+
+// DisplaySize returns dimensions the video is shown at: stretched by sample aspect ratio
+// and swapped for videos rotated by 90 or 270 degrees. Dimensions are rounded to even numbers
+// so they can be used as encoding resolution.
+func (m *Metadata) DisplaySize() (int, int) {
+	if !m.HasVideo() {
+		return 0, 0
+	}
+	vs := m.VideoStream
+	w, h := float64(vs.GetWidth()), float64(vs.GetHeight())
+	if sar := aspectRatio(vs.GetSampleAspectRatio()); sar > 0 {
+		w *= sar
+	}
+	dw, dh := roundEven(w), roundEven(h)
+	if m.Rotation == 90 || m.Rotation == 270 {
+		dw, dh = dh, dw
+	}
+	return dw, dh
+}
+
+// Anamorphic tells if the video has non-square pixels.
+func (m *Metadata) Anamorphic() bool {
+	if !m.HasVideo() {
+		return false
+	}
+	sar := aspectRatio(m.VideoStream.GetSampleAspectRatio())
+	return sar > 0 && sar != 1
+}
+
+// aspectRatio parses ffprobe N:M aspect ratio, returning 0 for missing or undefined (0:1) values.
+func aspectRatio(r string) float64 {
+	m := aspectRatioRe.FindStringSubmatch(r)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(m[1])
+	d, _ := strconv.Atoi(m[2])
+	if n == 0 || d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func roundEven(v float64) int {
+	return int(math.Round(v/2)) * 2
+}
@@ -0,0 +1,100 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRotation(t *testing.T) {
+	cases := []struct {
+		probe    string
+		rotation int
+	}{
+		{`{"index": 1, "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`, 90},
+		{`{"index": 1, "side_data_list": [{"side_data_type": "Display Matrix", "rotation": 90}]}`, 270},
+		{`{"index": 1, "side_data_list": [{"side_data_type": "Display Matrix", "rotation": 180}]}`, 180},
+		{`{"index": 1, "tags": {"rotate": "90"}}`, 90},
+		{`{"index": 1, "tags": {"rotate": "360"}}`, 0},
+		{`{"index": 1}`, 0},
+	}
+	for _, c := range cases {
+		meta := generateMeta(1920, 1080, 8000, "30/1")
+		meta.Streams[1].Index = 1
+		m, err := WrapMeta(&meta)
+		require.NoError(t, err)
+		require.NoError(t, m.ReadRotation([]byte(`{"streams": [{"index": 0}, `+c.probe+`]}`)))
+		assert.Equal(t, c.rotation, m.Rotation, c.probe)
+	}
+}
+
+func TestReadRotationTagAndSideDataAgree(t *testing.T) {
+	// ffprobe reports a video rotated clockwise by 90 degrees with both forms depending on its version.
+	for _, probe := range []string{
+		`{"index": 1, "tags": {"rotate": "90"}}`,
+		`{"index": 1, "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`,
+		`{"index": 1, "tags": {"rotate": "90"}, "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]}`,
+	} {
+		meta := generateMeta(1920, 1080, 8000, "30/1")
+		meta.Streams[1].Index = 1
+		m, err := WrapMeta(&meta)
+		require.NoError(t, err)
+		require.NoError(t, m.ReadRotation([]byte(`{"streams": [`+probe+`]}`)))
+		assert.Equal(t, 90, m.Rotation, probe)
+	}
+}
+
+func TestDisplaySize(t *testing.T) {
+	cases := []struct {
+		w, h        int
+		sar, dar    string
+		rotation    int
+		dw, dh      int
+		anamorphic  bool
+		description string
+	}{
+		{1920, 1080, "1:1", "16:9", 0, 1920, 1080, false, "square pixels"},
+		{1920, 1080, "", "", 90, 1080, 1920, false, "rotated"},
+		{1440, 1080, "4:3", "16:9", 0, 1920, 1080, true, "anamorphic"},
+		{720, 480, "0:1", "0:1", 0, 720, 480, false, "undefined sar"},
+		{1281, 721, "", "", 270, 722, 1282, false, "odd dimensions"},
+	}
+	for _, c := range cases {
+		meta := generateMeta(c.w, c.h, 8000, "30/1")
+		meta.Streams[1].SampleAspectRatio = c.sar
+		meta.Streams[1].DisplayAspectRatio = c.dar
+		m, err := WrapMeta(&meta)
+		require.NoError(t, err)
+		m.Rotation = c.rotation
+		w, h := m.DisplaySize()
+		assert.Equal(t, c.dw, w, c.description)
+		assert.Equal(t, c.dh, h, c.description)
+		assert.Equal(t, c.anamorphic, m.Anamorphic(), c.description)
+	}
+}
+
+func TestTweakRotatedAnamorphic(t *testing.T) {
+	meta := generateMeta(1920, 1080, 8000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	m.Rotation = 90
+	l, err := Default.Tweak(m)
+	require.NoError(t, err)
+	assert.Equal(t, 1080, l.Tiers[0].Width)
+	assert.Equal(t, 1920, l.Tiers[0].Height)
+	args := strings.Join(l.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(t, args, "-filter:v:0 scale=-2:1920 ")
+
+	meta = generateMeta(1440, 1080, 8000, "30/1")
+	meta.Streams[1].SampleAspectRatio = "4:3"
+	m, err = WrapMeta(&meta)
+	require.NoError(t, err)
+	l, err = Default.Tweak(m)
+	require.NoError(t, err)
+	assert.Equal(t, 1920, l.Tiers[0].Width)
+	args = strings.Join(l.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(t, args, "-filter:v:0 scale=1920:1080,setsar=1 ")
+	assert.Contains(t, args, "-filter:v:1 scale=1280:720,setsar=1 ")
+}
@@ -0,0 +1,86 @@
+package ladder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// maxFramerate is the highest frame rate considered real, container time bases (90000/1 etc.)
+// are sometimes reported in place of frame rates.
+const maxFramerate = 240
+
+// vfrTolerance is the relative difference between real and average frame rates above which the video is considered VFR.
+const vfrTolerance = 0.001
+
+var (
+	fpsPattern = regexp.MustCompile(`^(\d+)/(\d+)$`)
+
+	// standardFramerates are constant rates variable frame rate sources are normalized to.
+	standardFramerates = []string{"10", "12", "15", "20", "24000/1001", "24", "25", "30000/1001", "30", "48", "50", "60000/1001", "60"}
+)
+
+// FramerateError is returned when neither average nor real frame rate of the video stream is usable.
+type FramerateError struct {
+	AvgFrameRate string
+	RFrameRate   string
+}
+
+func (e FramerateError) Error() string {
+	return fmt.Sprintf("no usable frame rate (avg_frame_rate %q, r_frame_rate %q)", e.AvgFrameRate, e.RFrameRate)
+}
+
+// determineFramerate picks the frame rate to encode the video at and tells if the source has variable frame rate.
+// VFR is detected by average frame rate differing from the real (base) one, such video is encoded
+// at a standard frame rate nearest to the average.
+func (m *Metadata) determineFramerate() (*FPS, bool, error) {
+	avgRate, rRate := m.VideoStream.GetAvgFrameRate(), m.VideoStream.GetRFrameRrate()
+	avg, avgErr := parseFramerate(avgRate)
+	r, rErr := parseFramerate(rRate)
+	switch {
+	case avgErr != nil && rErr != nil:
+		return nil, false, FramerateError{AvgFrameRate: avgRate, RFrameRate: rRate}
+	case avgErr != nil:
+		return r, false, nil
+	case rErr != nil:
+		return avg, false, nil
+	}
+	if math.Abs(r.Float-avg.Float)/avg.Float <= vfrTolerance {
+		return avg, false, nil
+	}
+	return nearestStandardFramerate(avg.Float), true, nil
+}
+
+// parseFramerate parses ffprobe frame rate given either as a ratio or a decimal number.
+func parseFramerate(fr string) (*FPS, error) {
+	var v float64
+	if m := fpsPattern.FindStringSubmatch(fr); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		d, _ := strconv.Atoi(m[2])
+		if d == 0 {
+			return nil, fmt.Errorf("zero divisor in %q", fr)
+		}
+		v = float64(n) / float64(d)
+	} else {
+		var err error
+		if v, err = strconv.ParseFloat(fr, 64); err != nil {
+			return nil, fmt.Errorf("invalid frame rate %q", fr)
+		}
+	}
+	if v <= 0 || v > maxFramerate {
+		return nil, fmt.Errorf("frame rate %q is out of range", fr)
+	}
+	return &FPS{Ratio: fr, Float: v}, nil
+}
+
+func nearestStandardFramerate(fps float64) *FPS {
+	var best *FPS
+	for _, s := range standardFramerates {
+		f, _ := parseFramerate(s)
+		if best == nil || math.Abs(f.Float-fps) < math.Abs(best.Float-fps) {
+			best = f
+		}
+	}
+	return best
+}
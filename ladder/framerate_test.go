@@ -0,0 +1,55 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetermineFramerate(t *testing.T) {
+	cases := []struct {
+		avg, r string
+		fps    string
+		vfr    bool
+	}{
+		{"30/1", "30/1", "30/1", false},
+		{"30000/1001", "30000/1001", "30000/1001", false},
+		{"25", "", "25", false},
+		{"0/0", "24/1", "24/1", false},
+		{"2997/100", "90000/1", "2997/100", false},
+		{"1796/60", "30/1", "30000/1001", true},
+		{"5989/100", "120/1", "60000/1001", true},
+		{"1420/100", "30/1", "15", true},
+	}
+	for _, c := range cases {
+		meta := generateMeta(1920, 1080, 8000, c.avg)
+		meta.Streams[1].RFrameRrate = c.r
+		m, err := WrapMeta(&meta)
+		require.NoError(t, err, c.avg)
+		assert.Equal(t, c.fps, m.FPS.String(), c.avg)
+		assert.Equal(t, c.vfr, m.VFR, c.avg)
+	}
+
+	meta := generateMeta(1920, 1080, 8000, "0/0")
+	meta.Streams[1].RFrameRrate = "90000/1"
+	_, err := WrapMeta(&meta)
+	var ferr FramerateError
+	require.ErrorAs(t, err, &ferr)
+	assert.Equal(t, FramerateError{AvgFrameRate: "0/0", RFrameRate: "90000/1"}, ferr)
+}
+
+func TestTweakVFR(t *testing.T) {
+	meta := generateMeta(1280, 720, 3000, "1796/60")
+	meta.Streams[1].RFrameRrate = "30/1"
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	l, err := Default.Tweak(m)
+	require.NoError(t, err)
+	l.Tiers[0].KeepFramerate = true
+
+	args := strings.Join(l.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(t, args, "-r:v:0 30000/1001 -g:v:0 60", "VFR should be converted to constant frame rate")
+	assert.Contains(t, args, "-r:v:2 15 -g:v:2 30")
+}
@@ -1,6 +1,7 @@
 package ladder
 
 import (
+	"fmt"
 	"math"
 	"strconv"
 	"strings"
@@ -12,11 +13,32 @@ import (
 type Definition string
 
 type Ladder struct {
-	Args     map[string]string
-	Metadata *Metadata
-	Tiers    []Tier `yaml:",flow"`
+	Args            map[string]string
+	Metadata        *Metadata
+	Tiers           []Tier          `yaml:",flow"`
+	ComplexityProbe ComplexityProbe `yaml:"complexity_probe"`
+	// SourceTier defines what to do when video resolution does not match any of the ladder tiers.
+	SourceTier SourceTierMode `yaml:"source_tier"`
+	Audio      Audio
+	// SegmentType is the HLS segment container, fMP4 is also used regardless of it when a ladder codec requires that.
+	SegmentType SegmentType `yaml:"segment_type"`
+	// DASH enables MPEG-DASH manifest alongside HLS playlists. It implies fMP4 segments and shared audio.
+	DASH bool `yaml:"dash"`
+	// Progressive is an optional tier encoded into a single faststart MP4 file for players without HLS support.
+	Progressive *Tier `yaml:",omitempty"`
+	// Remux enables making the top tier out of the source video stream without re-encoding it,
+	// when the source already matches the tier.
+	Remux bool `yaml:"remux"`
 }
 
+// SegmentType is an HLS segment container format.
+type SegmentType string
+
+const (
+	SegmentTypeMPEGTS SegmentType = "mpegts"
+	SegmentTypeFMP4   SegmentType = "fmp4"
+)
+
 type Tier struct {
 	Definition    Definition
 	Height        int
@@ -28,6 +50,12 @@ type Tier struct {
 	BitrateCutoff int             `yaml:"bitrate_cutoff"`
 	CRF           int
 	AudioChannels int
+	Codec         Codec `yaml:",omitempty"`
+	// HDR tiers preserve HDR of the source in 10-bit video and are skipped for SDR sources.
+	// Other tiers of HDR sources are tone mapped to SDR.
+	HDR bool `yaml:"hdr,omitempty"`
+	// Copy is set by Tweak when the tier is to be made by segmenting the source video stream as is.
+	Copy bool `yaml:"-"`
 }
 
 func Load(yamlLadder []byte) (Ladder, error) {
@@ -37,20 +65,71 @@ func Load(yamlLadder []byte) (Ladder, error) {
 }
 
 // Tweak generates encoding parameters from the ladder for provided video metadata.
+// Tiers of multi-codec ladders are processed separately for each codec and grouped by codec in the output,
+// in order the codecs first appear in the ladder.
+// Within each codec, HDR tiers are processed separately from SDR ones and follow them in the output.
+// If metadata contains complexity probe results, tier bitrates and CRFs are adjusted accordingly.
+// With Remux enabled, the top tier is marked for copying when the source video matches it.
+// Sources without a video stream get a ladder with audio tiers only, while video sources no tier fits,
+// either by resolution or by bitrate cutoff, are an error.
 func (x Ladder) Tweak(md *Metadata) (Ladder, error) {
+	if !md.HasVideo() {
+		return x.tweakAudioOnly(md)
+	}
 	newLadder := Ladder{
-		Args:     x.Args,
-		Tiers:    []Tier{},
-		Metadata: md,
+		Args:            x.Args,
+		Tiers:           []Tier{},
+		Metadata:        md,
+		ComplexityProbe: x.ComplexityProbe,
+		SourceTier:      x.SourceTier,
+		Audio:           x.Audio,
+		SegmentType:     x.SegmentType,
+		DASH:            x.DASH,
+		Progressive:     x.progressiveTier(md),
+		Remux:           x.Remux,
+	}
+	for _, c := range x.Codecs() {
+		for _, hdr := range []bool{false, true} {
+			tiers := x.codecTiers(c, hdr)
+			if len(tiers) == 0 || (hdr && !md.Color.IsHDR()) {
+				continue
+			}
+			newLadder.Tiers = append(newLadder.Tiers, x.tweakTiers(tiers, md)...)
+		}
+	}
+	if len(newLadder.Tiers) == 0 {
+		w, h := md.DisplaySize()
+		return x, fmt.Errorf("no ladder tiers fit %dx%d video", w, h)
 	}
+	for i, t := range newLadder.Tiers {
+		newLadder.Tiers[i] = md.Complexity.apply(t)
+	}
+	if x.Remux && len(newLadder.Tiers) > 0 {
+		if err := md.checkRemux(newLadder.Tiers[0], x.h264Profile()); err != nil {
+			logger.Debugw("source not remuxable", "reason", err)
+		} else {
+			newLadder.Tiers[0].Copy = true
+		}
+	}
+	if newLadder.Audio.Bitrate == "" && len(newLadder.Tiers) > 0 {
+		newLadder.Audio.Bitrate = newLadder.Tiers[0].AudioBitrate
+	}
+
+	logger.Debugw("ladder built", "tiers", newLadder.Tiers)
+	return newLadder, nil
+}
+
+// tweakTiers selects tiers suitable for the stream and handles stream's own resolution according to SourceTier mode.
+func (x Ladder) tweakTiers(tiers []Tier, md *Metadata) []Tier {
+	newTiers := []Tier{}
+	tierIdx := []int{}
 	originalBitrate, _ := strconv.Atoi(md.VideoStream.GetBitRate())
 	var vert, origResSeen bool
-	w := md.VideoStream.GetWidth()
-	h := md.VideoStream.GetHeight()
+	w, h := md.DisplaySize()
 	if h > w {
 		vert = true
 	}
-	for _, t := range x.Tiers {
+	for i, t := range tiers {
 		if t.BitrateCutoff >= originalBitrate {
 			logger.Debugw("video bitrate lower than cut-off", "bitrate", originalBitrate, "cutoff", t.BitrateCutoff)
 			if t.Height == h {
@@ -71,21 +150,69 @@ func (x Ladder) Tweak(md *Metadata) (Ladder, error) {
 		if t.CRF == 0 {
 			t.CRF = DefaultCRF
 		}
-		newLadder.Tiers = append(newLadder.Tiers, t)
+		newTiers = append(newTiers, t)
+		tierIdx = append(tierIdx, i)
 	}
 
-	if !origResSeen && x.Tiers[0].Height >= h && len(newLadder.Tiers) > 0 {
-		newLadder.Tiers = append([]Tier{{
-			Height:       h,
-			Width:        w,
-			VideoBitrate: nsRate(w, h),
-			AudioBitrate: "128k",
-			CRF:          DefaultCRF,
-		}}, newLadder.Tiers...)
+	if origResSeen || len(newTiers) == 0 || tiers[0].Height < h {
+		return newTiers
 	}
 
-	logger.Debugw("ladder built", "tiers", newLadder.Tiers)
-	return newLadder, nil
+	switch x.SourceTier {
+	case SourceTierSkip:
+		logger.Debugw("skipping source resolution tier", "width", w, "height", h)
+	case SourceTierSnap:
+		n := nearestTier(tiers, w, h)
+		for i := range newTiers {
+			if tierIdx[i] == n {
+				newTiers = append(newTiers[:i], newTiers[i+1:]...)
+				break
+			}
+		}
+		st := tiers[n]
+		st.Width, st.Height = w, h
+		st.VideoBitrate = int(math.Round(st.bitrateAt(md.FPS.Float)/1000)) * 1000
+		st.Framerate, st.KeepFramerate = decimal.Zero, false
+		if st.CRF == 0 {
+			st.CRF = DefaultCRF
+		}
+		newTiers = append([]Tier{st}, newTiers...)
+	default:
+		newTiers = append([]Tier{sourceTier(tiers, w, h, md.FPS.Float)}, newTiers...)
+	}
+	return newTiers
+}
+
+// Codecs returns a list of distinct video codecs used by the ladder, in order of their appearance.
+func (x Ladder) Codecs() []Codec {
+	codecs := []Codec{}
+	seen := map[Codec]bool{}
+	for _, t := range x.Tiers {
+		if !seen[t.codec()] {
+			seen[t.codec()] = true
+			codecs = append(codecs, t.codec())
+		}
+	}
+	return codecs
+}
+
+// CodecNames returns codec family names (h264, hevc, av1) of the ladder video codecs.
+func (x Ladder) CodecNames() []string {
+	names := []string{}
+	for _, c := range x.Codecs() {
+		names = append(names, c.Name())
+	}
+	return names
+}
+
+func (x Ladder) codecTiers(c Codec, hdr bool) []Tier {
+	tiers := []Tier{}
+	for _, t := range x.Tiers {
+		if t.codec() == c && t.HDR == hdr {
+			tiers = append(tiers, t)
+		}
+	}
+	return tiers
 }
 
 func (x Ladder) ArgumentSet(output string) *ArgumentSet {
@@ -101,10 +228,77 @@ func (x Ladder) ArgumentSet(output string) *ArgumentSet {
 	}
 }
 
+// VideoCodecTag returns RFC 6381 codec string for the tier number n.
+func (x Ladder) VideoCodecTag(n int) string {
+	t := x.Tiers[n]
+	fps := 30.0
+	switch {
+	case !t.Framerate.IsZero():
+		fps = t.Framerate.InexactFloat64()
+	case x.Metadata != nil && x.Metadata.FPS != nil:
+		fps = x.Metadata.FPS.Float
+	}
+	switch {
+	case t.Copy && x.Metadata != nil:
+		return x.Metadata.sourceCodecTag()
+	case t.HDR:
+		return t.codec().tag(t.Width, t.Height, fps, 10, x.h264Profile())
+	}
+	return t.codec().tag(t.Width, t.Height, fps, 8, x.h264Profile())
+}
+
+// h264Profile returns the H.264 profile set by profile:v ladder argument, defaultH264Profile if it is not set.
+// Unsupported profiles are reported by Validate.
+func (x Ladder) h264Profile() string {
+	if p := x.Args["profile:v"]; h264ProfileRank(p) >= 0 {
+		return p
+	}
+	return defaultH264Profile
+}
+
+// codecArguments returns per-stream encoder options for tiers of codec c.
+func (x Ladder) codecArguments(c Codec) [][2]string {
+	if c == CodecH264 {
+		return [][2]string{{"profile", x.h264Profile()}}
+	}
+	return codecArguments[c]
+}
+
+// FMP4 tells if HLS segments are fragmented MP4 rather than MPEG-TS, either as configured
+// or because it is required by DASH output or some of the ladder codecs.
+func (x Ladder) FMP4() bool {
+	if x.SegmentType == SegmentTypeFMP4 || x.DASH {
+		return true
+	}
+	for _, t := range x.Tiers {
+		if t.codec().needsFMP4() {
+			return true
+		}
+	}
+	return false
+}
+
+// SegmentDuration returns target HLS segment duration in seconds.
+func (x Ladder) SegmentDuration() float64 {
+	if d, err := strconv.ParseFloat(x.Args["hls_time"], 64); err == nil && d > 0 {
+		return d
+	}
+	d, _ := strconv.ParseFloat(hlsTime, 64)
+	return d
+}
+
 func (x Ladder) String() string {
 	return strings.Join(x.ArgumentSet("...").GetStrArguments(), " ")
 }
 
-func nsRate(w, h int) int {
-	return int(math.Ceil(float64(800*600) / nsRateFactor))
+// CodecName returns codec family name (h264, hevc, av1) of the tier.
+func (t Tier) CodecName() string {
+	return t.codec().Name()
+}
+
+func (t Tier) codec() Codec {
+	if t.Codec == "" {
+		return CodecH264
+	}
+	return t.Codec
 }
@@ -90,17 +90,17 @@ func TestTweak(t *testing.T) {
 		{
 			metadata: generateMeta(720, 480, 5000, "30/1"),
 			expectedTiers: []Tier{
-				{Width: 720, Height: 480, VideoBitrate: nsRate(720, 480), AudioBitrate: "128k", Framerate: FPS0},
+				{Width: 720, Height: 480, VideoBitrate: 833_000, AudioBitrate: "128k", Framerate: FPS0},
 				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", Framerate: FPS0},
 				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", Framerate: FPS15},
 			},
 			expectedArgs: []string{
 				"-var_stream_map v:0,a:0 v:1,a:1 v:2,a:2",
 				"-map v:0 -filter:v:0 scale=-2:480",
-				"-b:v:0 1297298",
+				"-b:v:0 833000",
 				"-crf:v:0 24",
-				"-maxrate:v:0 1297298",
-				"-bufsize:v:0 1297298",
+				"-maxrate:v:0 833000",
+				"-bufsize:v:0 833000",
 				"-r:v:0 30/1",
 				"-g:v:0 60",
 				"-map v:0 -filter:v:1 scale=-2:360",
@@ -125,17 +125,17 @@ func TestTweak(t *testing.T) {
 		{
 			metadata: generateMeta(720, 480, 5000, "189941760/7981033"),
 			expectedTiers: []Tier{
-				{Width: 720, Height: 480, VideoBitrate: nsRate(720, 480), AudioBitrate: "128k", Framerate: FPS0},
+				{Width: 720, Height: 480, VideoBitrate: 833_000, AudioBitrate: "128k", Framerate: FPS0},
 				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", Framerate: FPS0},
 				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", Framerate: FPS15},
 			},
 			expectedArgs: []string{
 				"-var_stream_map v:0,a:0 v:1,a:1 v:2,a:2",
 				"-map v:0 -filter:v:0 scale=-2:480",
-				"-b:v:0 1297298",
+				"-b:v:0 833000",
 				"-crf:v:0 24",
-				"-maxrate:v:0 1297298",
-				"-bufsize:v:0 1297298",
+				"-maxrate:v:0 833000",
+				"-bufsize:v:0 833000",
 				"-r:v:0 189941760/7981033",
 				"-g:v:0 48",
 				"-map v:0 -filter:v:1 scale=-2:360",
@@ -178,7 +178,7 @@ func TestTweak(t *testing.T) {
 		{
 			metadata: generateMeta(800, 600, 3000, "30/1"),
 			expectedTiers: []Tier{
-				{Width: 800, Height: 600, VideoBitrate: nsRate(800, 600), AudioBitrate: "128k", Framerate: FPS0},
+				{Width: 800, Height: 600, VideoBitrate: 1222_000, AudioBitrate: "128k", Framerate: FPS0},
 				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", Framerate: FPS0},
 				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", Framerate: FPS15},
 			},
@@ -201,10 +201,6 @@ func TestTweak(t *testing.T) {
 				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", Framerate: FPS15},
 			},
 		},
-		{
-			metadata:      generateMeta(100, 50, 110, "30/1"),
-			expectedTiers: []Tier{},
-		},
 		{
 			metadata: generateMeta(1080, 1920, 3000, "30/1"),
 			expectedTiers: []Tier{
@@ -271,6 +267,30 @@ func generateMetaVideoOnly(w, h, br int, fr string) ffmpeg.Metadata {
 	return meta
 }
 
+func TestTweakNoFittingTiers(t *testing.T) {
+	cutoff := Default
+	cutoff.Tiers = []Tier{{Definition: "720p", Width: 1280, Height: 720, VideoBitrate: 2500_000, AudioBitrate: "128k", BitrateCutoff: 3000_000}}
+	testCases := []struct {
+		name     string
+		ladder   Ladder
+		metadata ffmpeg.Metadata
+		err      string
+	}{
+		{"100x50", Default, generateMeta(100, 50, 110, "30/1"), "no ladder tiers fit 100x50 video"},
+		{"160x96", Default, generateMeta(160, 96, 200, "30/1"), "no ladder tiers fit 160x96 video"},
+		{"bitrate cutoff", cutoff, generateMeta(1280, 720, 2000, "30/1"), "no ladder tiers fit 1280x720 video"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := WrapMeta(&tc.metadata)
+			require.NoError(t, err)
+			_, err = tc.ladder.Tweak(m)
+			assert.EqualError(t, err, tc.err)
+			assert.False(t, Ladder{Metadata: m}.IsAudioOnly(), "video source must not be treated as audio-only")
+		})
+	}
+}
+
 func TestTweakVideoOnly(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -298,3 +318,381 @@ func TestTweakVideoOnly(t *testing.T) {
 	assert.NotContains(args, "-ac 2")
 	assert.NotContains(args, "-ar 44100")
 }
+
+func TestTweakCodecs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ladder, err := Load([]byte(`
+tiers:
+  - definition: 1080p
+    bitrate: 2000_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libsvtav1
+  - definition: 720p
+    bitrate: 1500_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+    codec: libx265
+  - definition: 360p
+    bitrate: 500_000
+    audio_bitrate: 96k
+    width: 640
+    height: 360
+`))
+	require.NoError(err)
+
+	meta := generateMeta(1920, 1080, 8000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	newLadder, err := ladder.Tweak(m)
+	require.NoError(err)
+	require.Len(newLadder.Tiers, 3)
+
+	args := strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-c:v:0 libsvtav1 -preset:v:0 8")
+	assert.Contains(args, "-c:v:1 libx265 -preset:v:1 veryfast -tag:v:1 hvc1")
+	// H.264 profile is set per tier even though the ladder has no global profile argument,
+	// so the Main profile tag below matches the encoded stream.
+	assert.Contains(args, "-c:v:2 libx264 -profile:v:2 main")
+	assert.NotContains(args, "-preset:v:2")
+	assert.Contains(args, "-hls_segment_type fmp4")
+	assert.Contains(args, "-hls_segment_filename v%v_s%06d.m4s")
+	assert.Contains(args, "-hls_fmp4_init_filename v%v_init.mp4")
+
+	assert.Equal("av01.0.08M.08", newLadder.VideoCodecTag(0))
+	assert.Equal("hvc1.1.6.L93.B0", newLadder.VideoCodecTag(1))
+	assert.Equal("avc1.4D401E", newLadder.VideoCodecTag(2))
+}
+
+func TestCodecTag(t *testing.T) {
+	testCases := []struct {
+		codec         Codec
+		width, height int
+		fps           float64
+		expected      string
+	}{
+		{CodecH264, 256, 144, 15, "avc1.4D400D"},
+		{CodecH264, 1280, 720, 30, "avc1.4D401F"},
+		{CodecH264, 1920, 1080, 30, "avc1.4D4028"},
+		{CodecH264, 1920, 1080, 60, "avc1.4D402A"},
+		{CodecHEVC, 1920, 1080, 30, "hvc1.1.6.L120.B0"},
+		{CodecHEVC, 3840, 2160, 60, "hvc1.1.6.L153.B0"},
+		{CodecAV1, 640, 360, 30, "av01.0.01M.08"},
+		{CodecAV1, 1920, 1080, 60, "av01.0.09M.08"},
+	}
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s_%vx%v@%v", tc.codec, tc.width, tc.height, tc.fps), func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.codec.Tag(tc.width, tc.height, tc.fps))
+		})
+	}
+}
+
+func TestTweakMultiCodec(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ladder, err := Load([]byte(`
+tiers:
+  - definition: 1080p
+    bitrate: 3500_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+  - definition: 720p
+    bitrate: 2500_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+  - definition: 1080p
+    bitrate: 2000_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libsvtav1
+  - definition: 720p
+    bitrate: 1200_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+    codec: libsvtav1
+`))
+	require.NoError(err)
+	assert.Equal([]Codec{CodecH264, CodecAV1}, ladder.Codecs())
+	assert.Equal([]string{"h264", "av1"}, ladder.CodecNames())
+
+	meta := generateMeta(1600, 900, 5000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	newLadder, err := ladder.Tweak(m)
+	require.NoError(err)
+
+	expectedTiers := []Tier{
+		{Width: 1600, Height: 900, VideoBitrate: 2950_000},
+		{Width: 1280, Height: 720, VideoBitrate: 2500_000},
+		{Width: 1600, Height: 900, VideoBitrate: 1560_000, Codec: CodecAV1},
+		{Width: 1280, Height: 720, VideoBitrate: 1200_000, Codec: CodecAV1},
+	}
+	require.Len(newLadder.Tiers, len(expectedTiers))
+	for i, tier := range newLadder.Tiers {
+		assert.Equal(expectedTiers[i].Width, tier.Width, tier)
+		assert.Equal(expectedTiers[i].Height, tier.Height, tier)
+		assert.Equal(expectedTiers[i].VideoBitrate, tier.VideoBitrate, tier)
+		assert.Equal(expectedTiers[i].Codec, tier.Codec, tier)
+	}
+
+	args := strings.Join(newLadder.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(args, "-var_stream_map v:0,a:0 v:1,a:1 v:2,a:2 v:3,a:3")
+	assert.Contains(args, "-c:v:1 libx264")
+	assert.Contains(args, "-c:v:2 libsvtav1")
+	assert.Contains(args, "-hls_segment_type fmp4")
+}
+
+func TestComplexityProbe(t *testing.T) {
+	probe := Default.ComplexityProbe
+	assert.False(t, probe.Enabled)
+	assert.Equal(t, 600_000, probe.ReferenceBitrate)
+
+	testCases := []struct {
+		sampleBitrate  int
+		expectedFactor float64
+	}{
+		{600_000, 1},
+		{450_000, 0.75},
+		{100_000, 0.5},
+		{840_000, 1.4},
+		{3000_000, 1.5},
+		{0, 1},
+	}
+	for _, tc := range testCases {
+		c := probe.Evaluate(tc.sampleBitrate)
+		assert.Equal(t, tc.sampleBitrate, c.SampleBitrate)
+		assert.Equal(t, tc.expectedFactor, c.Factor, tc.sampleBitrate)
+	}
+}
+
+func TestTweakComplexity(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	testCases := []struct {
+		factor        float64
+		expectedTiers []Tier
+	}{
+		{
+			factor: 0.5,
+			expectedTiers: []Tier{
+				{Height: 1080, VideoBitrate: 1750_000, CRF: 25},
+				{Height: 720, VideoBitrate: 1250_000, CRF: 26},
+				{Height: 360, VideoBitrate: 250_000, CRF: 27},
+				{Height: 144, VideoBitrate: 50_000, CRF: 28},
+			},
+		},
+		{
+			factor: 1.5,
+			expectedTiers: []Tier{
+				{Height: 1080, VideoBitrate: 5250_000, CRF: 21},
+				{Height: 720, VideoBitrate: 3750_000, CRF: 22},
+				{Height: 360, VideoBitrate: 750_000, CRF: 23},
+				{Height: 144, VideoBitrate: 150_000, CRF: 24},
+			},
+		},
+		{
+			factor: 1,
+			expectedTiers: []Tier{
+				{Height: 1080, VideoBitrate: 3500_000, CRF: 23},
+				{Height: 720, VideoBitrate: 2500_000, CRF: 24},
+				{Height: 360, VideoBitrate: 500_000, CRF: 25},
+				{Height: 144, VideoBitrate: 100_000, CRF: 26},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		meta := generateMeta(1920, 1080, 8000, "30/1")
+		m, err := WrapMeta(&meta)
+		require.NoError(err)
+		m.Complexity = &Complexity{Factor: tc.factor}
+		newLadder, err := Default.Tweak(m)
+		require.NoError(err)
+		require.Len(newLadder.Tiers, len(tc.expectedTiers))
+		for i, tier := range newLadder.Tiers {
+			assert.Equal(tc.expectedTiers[i].Height, tier.Height, tier)
+			assert.Equal(tc.expectedTiers[i].VideoBitrate, tier.VideoBitrate, tier)
+			assert.Equal(tc.expectedTiers[i].CRF, tier.CRF, tier)
+		}
+	}
+}
+
+func TestTweakSourceTier(t *testing.T) {
+	testCases := []struct {
+		name          string
+		mode          SourceTierMode
+		metadata      ffmpeg.Metadata
+		expectedTiers []Tier
+	}{
+		{
+			name:     "InsertInterpolated",
+			mode:     SourceTierInsert,
+			metadata: generateMeta(720, 480, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Definition: "480p", Width: 720, Height: 480, VideoBitrate: 833_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "InsertDefaultMode",
+			metadata: generateMeta(1600, 900, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 1600, Height: 900, VideoBitrate: 2950_000, AudioBitrate: "160k", CRF: 23},
+				{Width: 1280, Height: 720, VideoBitrate: 2500_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "InsertFixedFramerateNeighbour",
+			mode:     SourceTierInsert,
+			metadata: generateMeta(320, 180, 1000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 320, Height: 180, VideoBitrate: 232_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "InsertFixedFramerateNeighbourHighFPS",
+			mode:     SourceTierInsert,
+			metadata: generateMeta(320, 180, 1000, "60/1"),
+			expectedTiers: []Tier{
+				{Width: 320, Height: 180, VideoBitrate: 411_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "InsertVertical",
+			mode:     SourceTierInsert,
+			metadata: generateMeta(600, 800, 3000, "30/1"),
+			expectedTiers: []Tier{
+				{Definition: "600p", Width: 600, Height: 800, VideoBitrate: 1222_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 360, Height: 640, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 144, Height: 256, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "SnapDown",
+			mode:     SourceTierSnap,
+			metadata: generateMeta(1600, 900, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 1600, Height: 900, VideoBitrate: 2500_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "SnapUp",
+			mode:     SourceTierSnap,
+			metadata: generateMeta(1800, 1012, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 1800, Height: 1012, VideoBitrate: 3500_000, AudioBitrate: "160k", CRF: 23},
+				{Width: 1280, Height: 720, VideoBitrate: 2500_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "SnapFixedFramerateHighFPS",
+			mode:     SourceTierSnap,
+			metadata: generateMeta(320, 180, 1000, "60/1"),
+			expectedTiers: []Tier{
+				{Width: 320, Height: 180, VideoBitrate: 400_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "Skip",
+			mode:     SourceTierSkip,
+			metadata: generateMeta(720, 480, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+		{
+			name:     "MatchingResolution",
+			mode:     SourceTierInsert,
+			metadata: generateMeta(1280, 720, 5000, "30/1"),
+			expectedTiers: []Tier{
+				{Width: 1280, Height: 720, VideoBitrate: 2500_000, AudioBitrate: "128k", CRF: 24},
+				{Width: 640, Height: 360, VideoBitrate: 500_000, AudioBitrate: "96k", CRF: 25},
+				{Width: 256, Height: 144, VideoBitrate: 100_000, AudioBitrate: "96k", CRF: 26},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			l := Default
+			l.SourceTier = tc.mode
+			m, err := WrapMeta(&tc.metadata)
+			require.NoError(t, err)
+			newLadder, err := l.Tweak(m)
+			require.NoError(t, err)
+			require.Len(t, newLadder.Tiers, len(tc.expectedTiers), newLadder.Tiers)
+			for i, tier := range newLadder.Tiers {
+				assert.Equal(t, tc.expectedTiers[i].Width, tier.Width, tier)
+				assert.Equal(t, tc.expectedTiers[i].Height, tier.Height, tier)
+				assert.Equal(t, tc.expectedTiers[i].VideoBitrate, tier.VideoBitrate, tier)
+				assert.Equal(t, tc.expectedTiers[i].AudioBitrate, tier.AudioBitrate, tier)
+				assert.Equal(t, tc.expectedTiers[i].CRF, tier.CRF, tier)
+				if tc.expectedTiers[i].Definition != "" {
+					assert.Equal(t, tc.expectedTiers[i].Definition, tier.Definition, tier)
+				}
+			}
+			assert.True(t, newLadder.Tiers[0].Framerate.IsZero(), "top tier is encoded at source frame rate")
+		})
+	}
+}
+
+func TestSegmentTypeFMP4(t *testing.T) {
+	l, err := Load(defaultLadderYaml)
+	require.NoError(t, err)
+	meta := generateMeta(1280, 720, 3000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+
+	tl, err := l.Tweak(m)
+	require.NoError(t, err)
+	assert.False(t, tl.FMP4())
+
+	l.SegmentType = SegmentTypeFMP4
+	tl, err = l.Tweak(m)
+	require.NoError(t, err)
+	assert.True(t, tl.FMP4())
+	args := strings.Join(tl.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(t, args, "-hls_segment_type fmp4")
+	assert.Contains(t, args, "-hls_segment_filename v%v_s%06d.m4s")
+	assert.Contains(t, args, "-hls_fmp4_init_filename v%v_init.mp4")
+
+	assert.EqualError(t,
+		Validate([]byte("segment_type: webm\ntiers: [{definition: 720p, width: 1280, height: 720, bitrate: 1, audio_bitrate: 96k}]")),
+		`segment_type: unknown segment type "webm"`)
+}
+
+func TestDASH(t *testing.T) {
+	l, err := Load(defaultLadderYaml)
+	require.NoError(t, err)
+	require.False(t, l.DASH)
+	meta := generateMeta(1280, 720, 3000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+
+	l.DASH = true
+	tl, err := l.Tweak(m)
+	require.NoError(t, err)
+	assert.True(t, tl.DASH)
+	assert.True(t, tl.FMP4())
+	assert.False(t, tl.Audio.Shared)
+	assert.True(t, tl.SharedAudio())
+}
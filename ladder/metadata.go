@@ -1,57 +1,130 @@
 package ladder
 
 import (
-	"fmt"
+	"encoding/json"
 	"math"
-	"regexp"
-	"strconv"
+	"strings"
 
 	"github.com/floostack/transcoder"
 	"github.com/floostack/transcoder/ffmpeg"
 	"github.com/pkg/errors"
+	"golang.org/x/text/language"
 )
 
 type Metadata struct {
-	FMeta       *ffmpeg.Metadata
-	FPS         *FPS
-	FastStart   bool
+	FMeta *ffmpeg.Metadata
+	FPS   *FPS
+	// FastStart is set for MP4 and MOV sources which have the movie box before media data.
+	FastStart bool
+	// Fragmented is set for fragmented MP4 sources.
+	Fragmented  bool
 	VideoStream transcoder.Streams
 	AudioStream transcoder.Streams
 	HasAudio    bool
+	// VFR is set for variable frame rate sources, which are converted to constant FPS.
+	VFR bool
+	// AudioTracks lists all source audio streams, in the order they appear in the source.
+	AudioTracks []AudioTrack
+	// SubtitleTracks lists all source subtitle streams, including ones which cannot be converted to WebVTT.
+	SubtitleTracks []SubtitleTrack
+	// Rotation is clockwise display rotation of the video in degrees (0, 90, 180 or 270), set by ReadRotation.
+	Rotation int
+	// Color holds video color properties. Transfer, primaries and matrix are only known after ReadColor is called.
+	Color Color
+	// Complexity is set when content complexity probe was performed on the video.
+	Complexity *Complexity
 }
 
+// FPS is the frame rate video is encoded at. For variable frame rate sources it is a standard rate
+// nearest to the average one rather than the source rate.
 type FPS struct {
 	Ratio string
 	Float float64
 }
 
-var fpsPattern = regexp.MustCompile(`^(\d+)/(\d+)$`)
-
 func WrapMeta(fmeta *ffmpeg.Metadata) (*Metadata, error) {
 	m := &Metadata{
 		FMeta: fmeta,
 	}
-	vs := m.videoStream()
-	if vs == nil {
-		return nil, errors.New("no video stream found")
-	}
-	m.VideoStream = vs
-
 	as := m.audioStream()
 	if as != nil {
 		m.AudioStream = as
 		m.HasAudio = true
 	}
+	m.AudioTracks = audioTracks(fmeta)
+	m.SubtitleTracks = subtitleTracks(fmeta)
 
-	f, err := m.determineFramerate()
+	vs := m.videoStream()
+	if vs == nil {
+		if m.HasAudio {
+			return m, nil
+		}
+		return nil, errors.New("no video or audio stream found")
+	}
+	m.VideoStream = vs
+	m.Color.BitDepth = pixFmtDepth(vs.GetPixFmt())
+
+	f, vfr, err := m.determineFramerate()
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot determine framerate")
 	}
 	m.FPS = f
+	m.VFR = vfr
 
 	return m, nil
 }
 
+// ReadStreamTags fills audio and subtitle track languages and titles from ffprobe JSON output,
+// since ffmpeg.Metadata does not carry stream tags.
+func (m *Metadata) ReadStreamTags(ffprobeJSON []byte) error {
+	var probe struct {
+		Streams []struct {
+			CodecType string            `json:"codec_type"`
+			Tags      map[string]string `json:"tags"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(ffprobeJSON, &probe); err != nil {
+		return err
+	}
+	var na, ns int
+	for _, s := range probe.Streams {
+		var lang, title *string
+		switch {
+		case s.CodecType == "audio" && na < len(m.AudioTracks):
+			lang, title = &m.AudioTracks[na].Language, &m.AudioTracks[na].Title
+			na++
+		case s.CodecType == "subtitle" && ns < len(m.SubtitleTracks):
+			lang, title = &m.SubtitleTracks[ns].Language, &m.SubtitleTracks[ns].Title
+			ns++
+		default:
+			continue
+		}
+		for k, v := range s.Tags {
+			switch strings.ToLower(k) {
+			case "language":
+				*lang = normalizeLanguage(v)
+			case "title":
+				*title = strings.TrimSpace(v)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeLanguage converts ISO 639-2 codes commonly found in containers to BCP 47 tags used by HLS.
+func normalizeLanguage(l string) string {
+	tag, err := language.Parse(strings.TrimSpace(l))
+	if err != nil || tag == language.Und {
+		return ""
+	}
+	return tag.String()
+}
+
+// HasVideo tells if the source has a video stream. Audio-only sources have no frame rate determined either.
+func (m *Metadata) HasVideo() bool {
+	return m.VideoStream != nil
+}
+
 func (m *Metadata) videoStream() transcoder.Streams {
 	return GetVideoStream(m.FMeta)
 }
@@ -65,26 +138,6 @@ func (m *Metadata) audioStream() transcoder.Streams {
 	return nil
 }
 
-func (m *Metadata) determineFramerate() (*FPS, error) {
-	fr := m.VideoStream.GetAvgFrameRate()
-	fpsm := fpsPattern.FindStringSubmatch(fr)
-	if len(fpsm) < 2 {
-		return nil, fmt.Errorf("no match found in %s", fr)
-	}
-	fpsdd, err := strconv.Atoi(fpsm[1])
-	if err != nil {
-		return nil, err
-	}
-	fpsds, err := strconv.Atoi(fpsm[2])
-	if err != nil {
-		return nil, err
-	}
-	if fpsds == 0 {
-		return nil, errors.New("divisor cannot be zero")
-	}
-	return &FPS{Ratio: fr, Float: float64(fpsdd) / float64(fpsds)}, nil
-}
-
 func (f FPS) Int() int {
 	return int(math.Ceil(f.Float))
 }
@@ -92,11 +145,21 @@ func (f FPS) Int() int {
 func (f FPS) String() string {
 	return f.Ratio
 }
+
+// GetVideoStream returns the first video stream, skipping still images (cover art) embedded in audio files.
 func GetVideoStream(meta *ffmpeg.Metadata) transcoder.Streams {
 	for _, s := range meta.GetStreams() {
-		if s.GetCodecType() == "video" {
+		if s.GetCodecType() == "video" && !isStillImage(s) {
 			return s
 		}
 	}
 	return nil
 }
+
+func isStillImage(s transcoder.Streams) bool {
+	switch s.GetCodecName() {
+	case "mjpeg", "png", "bmp", "gif", "webp":
+		return s.GetAvgFrameRate() == "0/0" || s.GetAvgFrameRate() == ""
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package ladder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultProfile is the name of ladder profile which is used when no profile is requested.
+const DefaultProfile = "default"
+
+var profileNameRe = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// Profiles is a registry of named ladders.
+type Profiles struct {
+	mu      sync.RWMutex
+	ladders map[string]Ladder
+}
+
+// NewProfiles creates a registry containing only the built-in default ladder.
+func NewProfiles() *Profiles {
+	return &Profiles{ladders: map[string]Ladder{DefaultProfile: Default}}
+}
+
+// LoadProfiles creates a registry of ladders from YAML files in dir, named after the files (sans extension).
+// A "default.yml" file in the directory overrides the built-in default ladder.
+// Profiles which fail validation or are named other than with lowercase letters, digits and dashes are rejected.
+func LoadProfiles(dir string) (*Profiles, error) {
+	p := NewProfiles()
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ext)
+		if !profileNameRe.MatchString(name) {
+			return nil, fmt.Errorf("invalid ladder profile name %q", name)
+		}
+		d, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if err := Validate(d); err != nil {
+			return nil, fmt.Errorf("invalid ladder profile %s: %w", f.Name(), err)
+		}
+		l, err := Load(d)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load ladder profile %s: %w", f.Name(), err)
+		}
+		p.Add(name, l)
+	}
+	return p, nil
+}
+
+// Add puts ladder into the registry under name, replacing existing ladder with the same name.
+func (p *Profiles) Add(name string, l Ladder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ladders[name] = l
+}
+
+// Get returns ladder for the profile name. Empty name returns the default ladder.
+func (p *Profiles) Get(name string) (Ladder, bool) {
+	if name == "" {
+		name = DefaultProfile
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	l, ok := p.ladders[name]
+	return l, ok
+}
+
+// Names returns a sorted list of registered profile names.
+func (p *Profiles) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	names := []string{}
+	for n := range p.ladders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
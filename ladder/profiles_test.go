@@ -0,0 +1,66 @@
+package ladder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProfiles(t *testing.T) {
+	p, err := LoadProfiles("./profiles")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"anime", "default", "low-cost", "podcast-audio-heavy"}, p.Names())
+
+	l, ok := p.Get("")
+	require.True(t, ok)
+	assert.Equal(t, Default, l)
+
+	l, ok = p.Get("podcast-audio-heavy")
+	require.True(t, ok)
+	assert.Equal(t, SourceTierSkip, l.SourceTier)
+	require.Len(t, l.Tiers, 2)
+	assert.Equal(t, "192k", l.Tiers[0].AudioBitrate)
+
+	_, ok = p.Get("nonexistent")
+	assert.False(t, ok)
+
+	meta := generateMeta(1920, 1080, 8000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	for _, n := range p.Names() {
+		l, _ := p.Get(n)
+		lt, err := l.Tweak(m)
+		require.NoError(t, err, n)
+		assert.NotEmpty(t, lt.Tiers, n)
+	}
+}
+
+func TestLoadProfilesOverrideDefault(t *testing.T) {
+	dir := t.TempDir()
+	d, err := os.ReadFile("./profiles/low-cost.yml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "default.yaml"), d, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("not a profile"), 0600))
+
+	p, err := LoadProfiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"default"}, p.Names())
+	l, _ := p.Get(DefaultProfile)
+	assert.Len(t, l.Tiers, 3)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yml"), []byte("tiers: ["), 0600))
+	_, err = LoadProfiles(dir)
+	assert.ErrorContains(t, err, "broken.yml")
+}
+
+func TestLoadProfilesInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	d, err := os.ReadFile("./profiles/low-cost.yml")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Low Cost.yml"), d, 0600))
+	_, err = LoadProfiles(dir)
+	assert.EqualError(t, err, `invalid ladder profile name "Low Cost"`)
+}
@@ -0,0 +1,97 @@
+package ladder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ProgressiveName is the file name of progressive MP4 rendition in the stream output directory.
+const ProgressiveName = "video.mp4"
+
+// progressiveTier fits progressive MP4 tier to the source, the same way ladder tiers are fitted:
+// dimensions are swapped for vertical videos and the tier is scaled down to the source resolution if it is higher.
+// Progressive rendition is always SDR since its purpose is to play anywhere. Complexity probe results apply to it too.
+func (x Ladder) progressiveTier(md *Metadata) *Tier {
+	if x.Progressive == nil {
+		return nil
+	}
+	t := *x.Progressive
+	w, h := md.DisplaySize()
+	if h > w {
+		t.Width, t.Height = t.Height, t.Width
+	}
+	if t.Height > h {
+		t.Width, t.Height = w, h
+	}
+	if t.CRF == 0 {
+		t.CRF = DefaultCRF
+	}
+	t.HDR = false
+	t = md.Complexity.apply(t)
+	return &t
+}
+
+// ProgressiveArguments returns ffmpeg output arguments for the progressive MP4 rendition, to be used after the input.
+// Ladder arguments are applied to it as well, except HLS-specific ones.
+// The rendition gets the default audio track, moov atom is moved to the front of the file so playback can start
+// before it is fully downloaded.
+func (x Ladder) ProgressiveArguments(output string) []string {
+	t := x.Progressive
+	if t == nil || x.Metadata == nil {
+		return nil
+	}
+	a := x.ArgumentSet(output)
+	args := []string{"-y"}
+	for k, v := range hlsDefaultArguments {
+		if _, ok := x.Args[k]; ok || !progressiveArgument(k) {
+			continue
+		}
+		args = append(args, "-"+k, v)
+	}
+	for k, v := range x.Args {
+		if progressiveArgument(k) {
+			args = append(args, "-"+k, v)
+		}
+	}
+
+	vRate := strconv.Itoa(t.VideoBitrate)
+	args = append(args,
+		"-map", "0:v:0",
+		"-filter:v:0", a.videoFilter(*t),
+		"-c:v:0", string(t.codec()),
+		"-crf:v:0", strconv.Itoa(t.CRF),
+		"-b:v:0", vRate,
+		"-maxrate:v:0", vRate,
+		"-bufsize:v:0", vRate,
+	)
+	for _, ca := range x.codecArguments(t.codec()) {
+		args = append(args, "-"+ca[0]+":v:0", ca[1])
+	}
+	args = append(args, a.colorArguments(*t, "0")...)
+	args = append(args, a.framerateArguments(*t, "0")...)
+
+	if x.Metadata.HasAudio {
+		track := x.AudioTracks()[x.DefaultAudioTrack()]
+		args = append(args, "-map", "0:a:"+strconv.Itoa(track.Index), "-b:a:0", t.AudioBitrate)
+		for k, v := range hlsAudioArguments {
+			if av, ok := x.Args[k]; ok {
+				v = av
+			}
+			args = append(args, "-"+k, v)
+		}
+	}
+	return append(args, "-movflags", "+faststart", "-f", "mp4", output)
+}
+
+// progressiveArgument tells if ffmpeg option k applies to progressive MP4 output.
+// HLS muxer options are left out, as well as codec and audio options which are set separately.
+func progressiveArgument(k string) bool {
+	if _, ok := hlsAudioArguments[k]; ok {
+		return false
+	}
+	switch k {
+	case "f", "c:v", "master_pl_name", "strftime_mkdir", argVarStreamMap:
+		return false
+	}
+	return !strings.HasPrefix(k, "hls_")
+}
@@ -0,0 +1,95 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTweakProgressive(t *testing.T) {
+	l, err := Load(defaultLadderYaml)
+	require.NoError(t, err)
+	p := &Tier{Definition: "720p", Width: 1280, Height: 720, VideoBitrate: 2000_000, AudioBitrate: "128k"}
+
+	cases := []struct {
+		w, h   int
+		tw, th int
+	}{
+		{1920, 1080, 1280, 720},
+		{1080, 1920, 720, 1280},
+		{854, 480, 854, 480},
+	}
+	for _, c := range cases {
+		meta := generateMeta(c.w, c.h, 8000, "30/1")
+		m, err := WrapMeta(&meta)
+		require.NoError(t, err)
+
+		tl, err := l.Tweak(m)
+		require.NoError(t, err)
+		assert.Nil(t, tl.Progressive)
+		assert.Nil(t, tl.ProgressiveArguments("/out/"+ProgressiveName))
+
+		l.Progressive = p
+		tl, err = l.Tweak(m)
+		l.Progressive = nil
+		require.NoError(t, err)
+		require.NotNil(t, tl.Progressive)
+		assert.Equal(t, c.tw, tl.Progressive.Width)
+		assert.Equal(t, c.th, tl.Progressive.Height)
+		assert.Equal(t, DefaultCRF, tl.Progressive.CRF)
+	}
+	assert.Equal(t, 1280, p.Width, "ladder tier must not be modified")
+}
+
+func TestProgressiveArguments(t *testing.T) {
+	l, err := Load(defaultLadderYaml)
+	require.NoError(t, err)
+	l.Progressive = &Tier{Definition: "720p", Width: 1280, Height: 720, VideoBitrate: 2000_000, AudioBitrate: "128k"}
+	meta := generateMeta(1920, 1080, 8000, "30/1")
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	tl, err := l.Tweak(m)
+	require.NoError(t, err)
+
+	args := strings.Join(tl.ProgressiveArguments("/out/video.mp4"), " ")
+	assert.Contains(t, args, "-map 0:v:0 -filter:v:0 scale=-2:720 -c:v:0 libx264 -crf:v:0 24 -b:v:0 2000000")
+	assert.Contains(t, args, "-r:v:0 30/1 -g:v:0 60")
+	assert.Contains(t, args, "-map 0:a:0 -b:a:0 128k")
+	assert.Contains(t, args, "-c:a aac")
+	assert.Contains(t, args, "-preset veryfast")
+	assert.Contains(t, args, "-profile:v main")
+	assert.NotContains(t, args, "hls")
+	assert.NotContains(t, args, "var_stream_map")
+	assert.NotContains(t, args, "-f hls")
+	assert.True(t, strings.HasSuffix(args, "-movflags +faststart -f mp4 /out/video.mp4"))
+
+	meta = generateMetaVideoOnly(1920, 1080, 8000, "30/1")
+	m, err = WrapMeta(&meta)
+	require.NoError(t, err)
+	tl, err = l.Tweak(m)
+	require.NoError(t, err)
+	assert.NotContains(t, strings.Join(tl.ProgressiveArguments("/out/video.mp4"), " "), "0:a:0")
+}
+
+func TestValidateProgressive(t *testing.T) {
+	err := Validate([]byte(`
+tiers:
+  - definition: 720p
+    bitrate: 1000_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+progressive:
+  definition: 720p
+  bitrate: 0
+  audio_bitrate: 128k
+  width: 1281
+  height: 720
+  hdr: true
+`))
+	assert.EqualError(t, err,
+		"progressive.width: 1281 is not even; progressive.bitrate: must be positive; "+
+			"progressive.hdr: progressive rendition cannot preserve HDR")
+}
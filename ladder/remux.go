@@ -0,0 +1,51 @@
+package ladder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// remuxBitrateTolerance is how much source bitrate may exceed the tier bitrate for the source to still be remuxed.
+const remuxBitrateTolerance = 1.2
+
+// checkRemux tells why the source video stream cannot be segmented for the tier as is, nil if it can.
+// Sources in H.264 profiles up to h264Profile, which other tiers are encoded with, are accepted.
+// Keyframe placement is not known from metadata and has to be checked separately.
+func (m *Metadata) checkRemux(t Tier, h264Profile string) error {
+	vs := m.VideoStream
+	w, h := m.DisplaySize()
+	btr, _ := strconv.Atoi(vs.GetBitRate())
+	switch {
+	case t.codec() != CodecH264 || t.HDR:
+		return fmt.Errorf("tier codec is %s", t.codec().Name())
+	case vs.GetCodecName() != "h264" || h264ProfileRank(vs.GetProfile()) < 0:
+		return fmt.Errorf("source is %s %s", vs.GetCodecName(), vs.GetProfile())
+	case h264ProfileRank(vs.GetProfile()) > h264ProfileRank(h264Profile):
+		return fmt.Errorf("source profile %s is above ladder profile %s", vs.GetProfile(), h264Profile)
+	case vs.GetPixFmt() != "yuv420p" || m.Color.IsHDR():
+		return fmt.Errorf("source pixel format is %s", vs.GetPixFmt())
+	case m.Anamorphic() || m.Rotation != 0:
+		return errors.New("source needs to be rotated or has non-square pixels")
+	case w != t.Width || h != t.Height:
+		return fmt.Errorf("source size %dx%d differs from tier size %dx%d", w, h, t.Width, t.Height)
+	case m.VFR:
+		return errors.New("source has variable frame rate")
+	case !t.KeepFramerate && !t.Framerate.IsZero() && math.Abs(t.Framerate.InexactFloat64()-m.FPS.Float) > 0.01:
+		return fmt.Errorf("source frame rate %s differs from tier frame rate %s", m.FPS.String(), t.Framerate)
+	case btr <= 0:
+		return errors.New("source bitrate is unknown")
+	case float64(btr) > float64(t.VideoBitrate)*remuxBitrateTolerance:
+		return fmt.Errorf("source bitrate %d is too high for tier bitrate %d", btr, t.VideoBitrate)
+	}
+	return nil
+}
+
+// sourceCodecTag returns RFC 6381 codec string of the source video stream copied by remuxing,
+// which keeps the source profile and level.
+func (m *Metadata) sourceCodecTag() string {
+	vs := m.VideoStream
+	return fmt.Sprintf("avc1.%s%02X", h264Profiles[max(h264ProfileRank(vs.GetProfile()), 0)].tag, vs.GetLevel())
+}
@@ -0,0 +1,124 @@
+package ladder
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func remuxableMeta() ffmpeg.Metadata {
+	meta := generateMeta(1920, 1080, 3800, "30/1")
+	meta.Streams[1].CodecName = "h264"
+	meta.Streams[1].Profile = "Main"
+	meta.Streams[1].PixFmt = "yuv420p"
+	return meta
+}
+
+func TestCheckRemux(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(m *ffmpeg.Metadata)
+		err    string
+	}{
+		{"compliant", func(m *ffmpeg.Metadata) {}, ""},
+		{"constrained baseline", func(m *ffmpeg.Metadata) { m.Streams[1].Profile = "Constrained Baseline" }, ""},
+		{"baseline", func(m *ffmpeg.Metadata) { m.Streams[1].Profile = "Baseline" }, "source is h264 Baseline"},
+		{"high profile", func(m *ffmpeg.Metadata) { m.Streams[1].Profile = "High" }, "source profile High is above ladder profile main"},
+		{"hevc", func(m *ffmpeg.Metadata) { m.Streams[1].CodecName = "hevc" }, "source is hevc Main"},
+		{"10 bit", func(m *ffmpeg.Metadata) { m.Streams[1].PixFmt = "yuv420p10le" }, "pixel format is yuv420p10le"},
+		{"smaller", func(m *ffmpeg.Metadata) { m.Streams[1].Width, m.Streams[1].Height = 1280, 720 }, "size 1280x720 differs"},
+		{"bitrate", func(m *ffmpeg.Metadata) { m.Streams[1].BitRate = "6000000" }, "bitrate 6000000 is too high"},
+		{"no bitrate", func(m *ffmpeg.Metadata) { m.Streams[1].BitRate = "" }, "bitrate is unknown"},
+		{"anamorphic", func(m *ffmpeg.Metadata) { m.Streams[1].SampleAspectRatio = "4:3" }, "non-square pixels"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			meta := remuxableMeta()
+			tc.modify(&meta)
+			m, err := WrapMeta(&meta)
+			require.NoError(t, err)
+			if tc.err == "" {
+				assert.NoError(t, m.checkRemux(Default.Tiers[0], "main"))
+			} else {
+				assert.ErrorContains(t, m.checkRemux(Default.Tiers[0], "main"), tc.err)
+			}
+		})
+	}
+}
+
+func TestCheckRemuxFramerate(t *testing.T) {
+	meta := remuxableMeta()
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	tier := Default.Tiers[0]
+	tier.Framerate = Default.Tiers[3].Framerate
+	assert.ErrorContains(t, m.checkRemux(tier, "main"), "frame rate 30/1 differs from tier frame rate 15")
+	tier.KeepFramerate = true
+	assert.NoError(t, m.checkRemux(tier, "main"))
+
+	meta.Streams[1].AvgFrameRate, meta.Streams[1].RFrameRrate = "1796/60", "30/1"
+	m, err = WrapMeta(&meta)
+	require.NoError(t, err)
+	assert.ErrorContains(t, m.checkRemux(Default.Tiers[0], "main"), "variable frame rate")
+}
+
+func TestTweakRemux(t *testing.T) {
+	meta := remuxableMeta()
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+	l, err := Default.Tweak(m)
+	require.NoError(t, err)
+	require.True(t, l.Tiers[0].Copy)
+	for _, tier := range l.Tiers[1:] {
+		assert.False(t, tier.Copy)
+	}
+
+	args := strings.Join(l.ArgumentSet("/usr/out").GetStrArguments(), " ")
+	assert.Contains(t, args, "-map v:0 -c:v:0 copy -map a:0 -b:a:0 160k -map v:0 -filter:v:1 scale=-2:720")
+	assert.NotContains(t, args, "-filter:v:0")
+	assert.NotContains(t, args, "-r:v:0")
+
+	noRemux := Default
+	noRemux.Remux = false
+	l, err = noRemux.Tweak(m)
+	require.NoError(t, err)
+	assert.False(t, l.Tiers[0].Copy)
+}
+
+func TestTweakRemuxHighProfile(t *testing.T) {
+	meta := remuxableMeta()
+	meta.Streams[1].Profile = "High"
+	meta.Streams[1].Level = 40
+	m, err := WrapMeta(&meta)
+	require.NoError(t, err)
+
+	l, err := Default.Tweak(m)
+	require.NoError(t, err)
+	assert.False(t, l.Tiers[0].Copy, "High profile source should not be copied into a Main profile ladder")
+
+	high := Default
+	high.Args = map[string]string{}
+	for k, v := range Default.Args {
+		high.Args[k] = v
+	}
+	high.Args["profile:v"] = "high"
+	l, err = high.Tweak(m)
+	require.NoError(t, err)
+	require.True(t, l.Tiers[0].Copy)
+	// Copied tier is described by the source profile and level rather than the ones computed for its resolution.
+	assert.Equal(t, "avc1.640028", l.VideoCodecTag(0))
+	assert.Equal(t, "avc1.64001F", l.VideoCodecTag(1))
+	assert.Contains(t, strings.Join(l.ArgumentSet("/usr/out").GetStrArguments(), " "), "-c:v:1 libx264 -profile:v:1 high")
+
+	meta.Streams[1].Profile = "Constrained Baseline"
+	meta.Streams[1].Level = 31
+	m, err = WrapMeta(&meta)
+	require.NoError(t, err)
+	l, err = high.Tweak(m)
+	require.NoError(t, err)
+	require.True(t, l.Tiers[0].Copy)
+	assert.Equal(t, "avc1.42E01F", l.VideoCodecTag(0))
+}
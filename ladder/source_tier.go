@@ -0,0 +1,102 @@
+package ladder
+
+import (
+	"fmt"
+	"math"
+)
+
+// SourceTierMode defines how a video with resolution not matching any of the ladder tiers is handled.
+type SourceTierMode string
+
+const (
+	// SourceTierInsert adds a tier with video's own resolution on top of the ladder.
+	// Its bitrate is interpolated from neighbouring tiers.
+	SourceTierInsert SourceTierMode = "insert"
+	// SourceTierSnap moves the ladder tier nearest by pixel count to video's own resolution.
+	// It is encoded at the video frame rate, with bitrate normalized to it.
+	SourceTierSnap SourceTierMode = "snap"
+	// SourceTierSkip leaves the ladder as is, making the nearest lower tier the top one.
+	SourceTierSkip SourceTierMode = "skip"
+)
+
+// sourceTier synthesizes a tier for w x h video at fps frame rate.
+// Neighbouring tier bitrates are first normalized to the video frame rate, then bitrate is interpolated
+// linearly by pixel count between the nearest lower and higher tiers. Audio bitrate and CRF are taken
+// from the nearest higher tier. The tier is labelled by its shorter side, 480p for both 854x480 and 480x854 video.
+func sourceTier(tiers []Tier, w, h int, fps float64) Tier {
+	px := float64(w * h)
+	var lower, upper *Tier
+	for i := range tiers {
+		t := &tiers[i]
+		if t.pixels() < px && (lower == nil || t.pixels() > lower.pixels()) {
+			lower = t
+		}
+		if t.pixels() > px && (upper == nil || t.pixels() < upper.pixels()) {
+			upper = t
+		}
+	}
+
+	var rate float64
+	switch {
+	case lower != nil && upper != nil:
+		lrate, urate := lower.bitrateAt(fps), upper.bitrateAt(fps)
+		rate = lrate + (urate-lrate)*(px-lower.pixels())/(upper.pixels()-lower.pixels())
+	case upper != nil:
+		rate = upper.bitrateAt(fps) * px / upper.pixels()
+	case lower != nil:
+		rate = lower.bitrateAt(fps) * px / lower.pixels()
+	}
+
+	t := Tier{
+		Definition:   Definition(fmt.Sprintf("%dp", min(w, h))),
+		Width:        w,
+		Height:       h,
+		VideoBitrate: int(math.Round(rate/1000)) * 1000,
+		CRF:          DefaultCRF,
+	}
+	ref := upper
+	if ref == nil {
+		ref = lower
+	}
+	if ref != nil {
+		t.Codec = ref.Codec
+		t.HDR = ref.HDR
+		t.AudioBitrate = ref.AudioBitrate
+		if ref.CRF != 0 {
+			t.CRF = ref.CRF
+		}
+	}
+	return t
+}
+
+func (t Tier) pixels() float64 {
+	return float64(t.Width * t.Height)
+}
+
+// bitrateAt returns tier bitrate scaled to srcFPS frame rate.
+// Tiers without fixed frame rate are encoded at the source frame rate so their bitrate is returned as is.
+func (t Tier) bitrateAt(srcFPS float64) float64 {
+	if t.Framerate.IsZero() || t.KeepFramerate || srcFPS <= 0 {
+		return float64(t.VideoBitrate)
+	}
+	return float64(t.VideoBitrate) * srcFPS / t.Framerate.InexactFloat64()
+}
+
+// nearestTier returns an index of the tier with pixel count nearest to w x h.
+func nearestTier(tiers []Tier, w, h int) int {
+	px := w * h
+	n := 0
+	for i, t := range tiers {
+		if absInt(t.Width*t.Height-px) < absInt(tiers[n].Width*tiers[n].Height-px) {
+			n = i
+		}
+	}
+	return n
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package ladder
+
+import (
+	"github.com/floostack/transcoder/ffmpeg"
+)
+
+// SubtitleGroup is the name of HLS subtitle rendition group referenced by video variants.
+const SubtitleGroup = "subtitles"
+
+// textSubtitleCodecs are subtitle codecs which ffmpeg can convert to WebVTT.
+// Bitmap subtitles (PGS, VobSub, DVB) would need OCR and are skipped.
+var textSubtitleCodecs = map[string]bool{
+	"mov_text": true,
+	"subrip":   true,
+	"srt":      true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"text":     true,
+}
+
+// SubtitleTrack is a source subtitle stream.
+type SubtitleTrack struct {
+	// Index is the number of the stream among source subtitle streams, as in ffmpeg "s:N" stream specifier.
+	Index    int
+	Codec    string
+	Language string
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+// IsText tells if the track can be converted to WebVTT.
+func (t SubtitleTrack) IsText() bool {
+	return textSubtitleCodecs[t.Codec]
+}
+
+func subtitleTracks(fmeta *ffmpeg.Metadata) []SubtitleTrack {
+	tracks := []SubtitleTrack{}
+	for _, s := range fmeta.GetStreams() {
+		if s.GetCodecType() != "subtitle" {
+			continue
+		}
+		d := s.GetDisposition()
+		tracks = append(tracks, SubtitleTrack{
+			Index:   len(tracks),
+			Codec:   s.GetCodecName(),
+			Default: d.GetDefault() == 1,
+			Forced:  d.GetForced() == 1,
+		})
+	}
+	return tracks
+}
+
+// TextSubtitleTracks returns source subtitle tracks which can be converted to WebVTT renditions.
+// Audio-only ladders get no subtitles.
+func (x Ladder) TextSubtitleTracks() []SubtitleTrack {
+	tracks := []SubtitleTrack{}
+	if x.Metadata == nil || x.IsAudioOnly() {
+		return tracks
+	}
+	for _, t := range x.Metadata.SubtitleTracks {
+		if t.IsText() {
+			tracks = append(tracks, t)
+		}
+	}
+	return tracks
+}
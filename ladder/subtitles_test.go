@@ -0,0 +1,49 @@
+package ladder
+
+import (
+	"testing"
+
+	"github.com/floostack/transcoder/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextSubtitleTracks(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	meta := ffmpeg.Metadata{
+		Format: ffmpeg.Format{BitRate: "5000000"},
+		Streams: []ffmpeg.Streams{
+			{CodecType: "video", BitRate: "5000000", Width: 1280, Height: 720, AvgFrameRate: "30/1"},
+			{CodecType: "audio"},
+			{CodecType: "subtitle", CodecName: "subrip"},
+			{CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+			{CodecType: "subtitle", CodecName: "ass", Disposition: ffmpeg.Disposition{Forced: 1}},
+		},
+	}
+	m, err := WrapMeta(&meta)
+	require.NoError(err)
+	require.NoError(m.ReadStreamTags([]byte(`{"streams": [
+		{"codec_type": "video"},
+		{"codec_type": "audio", "tags": {"language": "jpn"}},
+		{"codec_type": "subtitle", "tags": {"language": "eng", "title": "Full"}},
+		{"codec_type": "subtitle", "tags": {"language": "eng"}},
+		{"codec_type": "subtitle", "tags": {"language": "spa", "title": "Signs"}}
+	]}`)))
+	assert.Len(m.SubtitleTracks, 3)
+
+	newLadder, err := Default.Tweak(m)
+	require.NoError(err)
+	assert.Equal([]SubtitleTrack{
+		{Index: 0, Codec: "subrip", Language: "en", Title: "Full"},
+		{Index: 2, Codec: "ass", Language: "es", Title: "Signs", Forced: true},
+	}, newLadder.TextSubtitleTracks())
+
+	meta.Streams = meta.Streams[1:]
+	m, err = WrapMeta(&meta)
+	require.NoError(err)
+	newLadder, err = Default.Tweak(m)
+	require.NoError(err)
+	assert.Empty(newLadder.TextSubtitleTracks(), "audio-only ladders should have no subtitles")
+}
@@ -0,0 +1,171 @@
+package ladder
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var audioBitrateRe = regexp.MustCompile(`^[1-9][0-9]*(\.[0-9]+)?[kKM]?$`)
+
+// ValidationError describes a single problem found in the ladder definition.
+type ValidationError struct {
+	// Tier is the index of offending tier, -1 for problems not related to a specific tier.
+	Tier  int
+	Field string
+	Msg   string
+}
+
+// ValidationErrors is a list of all problems found in the ladder definition.
+type ValidationErrors []ValidationError
+
+func (e ValidationError) Error() string {
+	if e.Tier < 0 {
+		if e.Field == "" {
+			return e.Msg
+		}
+		return fmt.Sprintf("%s: %s", e.Field, e.Msg)
+	}
+	return fmt.Sprintf("tier %d: %s: %s", e.Tier, e.Field, e.Msg)
+}
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate parses YAML ladder definition, rejecting unknown keys, and checks it for errors.
+// Returned error is ValidationErrors unless the document cannot be parsed at all.
+func Validate(yamlLadder []byte) error {
+	var l Ladder
+	var errs ValidationErrors
+
+	dec := yaml.NewDecoder(bytes.NewReader(yamlLadder))
+	dec.KnownFields(true)
+	if err := dec.Decode(&l); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return err
+		}
+		for _, m := range typeErr.Errors {
+			errs = append(errs, ValidationError{Tier: -1, Msg: m})
+		}
+	}
+	errs = append(errs, l.validate()...)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validate checks that tiers have sane dimensions and bitrates, and that tiers of each codec
+// are ordered from the highest resolution to the lowest, with bitrates not increasing along the way.
+// HDR tiers of a codec are checked separately from its SDR tiers.
+func (x Ladder) validate() ValidationErrors {
+	var errs ValidationErrors
+	add := func(i int, field, format string, a ...any) {
+		errs = append(errs, ValidationError{Tier: i, Field: field, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	switch x.SourceTier {
+	case "", SourceTierInsert, SourceTierSnap, SourceTierSkip:
+	default:
+		add(-1, "source_tier", "unknown mode %q", x.SourceTier)
+	}
+	switch x.SegmentType {
+	case "", SegmentTypeMPEGTS, SegmentTypeFMP4:
+	default:
+		add(-1, "segment_type", "unknown segment type %q", x.SegmentType)
+	}
+	if p, ok := x.Args["profile:v"]; ok && h264ProfileRank(p) < 0 {
+		add(-1, "args.profile:v", "unsupported H.264 profile %q", p)
+	}
+	if len(x.Tiers) == 0 {
+		add(-1, "tiers", "ladder has no tiers")
+	}
+	if p := x.Progressive; p != nil {
+		p.validate(func(field, format string, a ...any) { add(-1, "progressive."+field, format, a...) })
+		if p.HDR {
+			add(-1, "progressive.hdr", "progressive rendition cannot preserve HDR")
+		}
+	}
+	if x.Audio.Bitrate != "" && !audioBitrateRe.MatchString(x.Audio.Bitrate) {
+		add(-1, "audio.bitrate", "invalid value %q", x.Audio.Bitrate)
+	}
+	for _, br := range x.Audio.Tiers {
+		if !audioBitrateRe.MatchString(br) {
+			add(-1, "audio.tiers", "invalid value %q", br)
+		}
+	}
+
+	type group struct {
+		codec Codec
+		hdr   bool
+	}
+	prev := map[group]int{}
+	defs := map[group]map[Definition]bool{}
+	for i, t := range x.Tiers {
+		t.validate(func(field, format string, a ...any) { add(i, field, format, a...) })
+		if _, ok := hdrCodecArguments[t.codec()]; t.HDR && !ok {
+			add(i, "hdr", "codec %s cannot preserve HDR", t.codec().Name())
+		}
+
+		c := group{t.codec(), t.HDR}
+		if defs[c] == nil {
+			defs[c] = map[Definition]bool{}
+		}
+		if t.Definition == "" {
+			add(i, "definition", "missing")
+		} else if defs[c][t.Definition] {
+			add(i, "definition", "duplicate definition %s", t.Definition)
+		}
+		defs[c][t.Definition] = true
+
+		j, ok := prev[c]
+		prev[c] = i
+		if !ok {
+			continue
+		}
+		p := x.Tiers[j]
+		if t.Height >= p.Height {
+			add(i, "height", "%d is not lower than %d of tier %d", t.Height, p.Height, j)
+		}
+		if p.VideoBitrate < t.VideoBitrate {
+			add(j, "bitrate", "%d is lower than %d of the next tier %d", p.VideoBitrate, t.VideoBitrate, i)
+		}
+	}
+	return errs
+}
+
+// validate checks tier dimensions, bitrates, CRF and codec, reporting problems via add.
+func (t Tier) validate(add func(field, format string, a ...any)) {
+	if t.Width <= 0 {
+		add("width", "must be positive")
+	} else if t.Width%2 != 0 {
+		add("width", "%d is not even", t.Width)
+	}
+	if t.Height <= 0 {
+		add("height", "must be positive")
+	} else if t.Height%2 != 0 {
+		add("height", "%d is not even", t.Height)
+	}
+	if t.VideoBitrate <= 0 {
+		add("bitrate", "must be positive")
+	}
+	if !audioBitrateRe.MatchString(t.AudioBitrate) {
+		add("audio_bitrate", "invalid value %q", t.AudioBitrate)
+	}
+	if t.CRF < 0 || t.CRF > 63 {
+		add("crf", "%d is out of range", t.CRF)
+	}
+	if _, ok := codecArguments[t.codec()]; !ok {
+		add("codec", "unsupported codec %q", t.Codec)
+	}
+}
@@ -0,0 +1,108 @@
+package ladder
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDefaults(t *testing.T) {
+	require.NoError(t, Validate(defaultLadderYaml))
+
+	files, err := os.ReadDir("./profiles")
+	require.NoError(t, err)
+	for _, f := range files {
+		d, err := os.ReadFile("./profiles/" + f.Name())
+		require.NoError(t, err)
+		assert.NoError(t, Validate(d), f.Name())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Validate([]byte(`
+source_tier: stretch
+tiers:
+  - definition: 720p
+    bitrate: 1000_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+    crf: 24
+    bitrat: 1
+  - definition: 1080p
+    bitrate: 3500_000
+    audio_bitrate: 160
+    width: 1920
+    height: 1080
+  - definition: 1080p
+    bitrate: 500_000
+    audio_bitrate: 96kbps
+    width: 641
+    height: 0
+  - definition: 1080p
+    bitrate: 2000_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libx265
+  - definition: 720p
+    bitrate: 1000_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+    codec: libvpx
+`))
+	require.Error(t, err)
+	var verrs ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+
+	assert.Equal(t, ValidationErrors{
+		{Tier: -1, Msg: "line 10: field bitrat not found in type ladder.Tier"},
+		{Tier: -1, Field: "source_tier", Msg: `unknown mode "stretch"`},
+		{Tier: 1, Field: "height", Msg: "1080 is not lower than 720 of tier 0"},
+		{Tier: 0, Field: "bitrate", Msg: "1000000 is lower than 3500000 of the next tier 1"},
+		{Tier: 2, Field: "width", Msg: "641 is not even"},
+		{Tier: 2, Field: "height", Msg: "must be positive"},
+		{Tier: 2, Field: "audio_bitrate", Msg: `invalid value "96kbps"`},
+		{Tier: 2, Field: "definition", Msg: "duplicate definition 1080p"},
+		{Tier: 4, Field: "codec", Msg: `unsupported codec "libvpx"`},
+	}, verrs)
+	assert.Contains(t, err.Error(), "tier 2: width: 641 is not even")
+
+	assert.EqualError(t, Validate([]byte("tiers: [")), "yaml: line 1: did not find expected node content")
+	assert.EqualError(t, Validate([]byte("args: {}")), "tiers: ladder has no tiers")
+	assert.EqualError(t, Validate([]byte("args: {profile:v: high10}")),
+		`args.profile:v: unsupported H.264 profile "high10"; tiers: ladder has no tiers`)
+}
+
+func TestValidateHDR(t *testing.T) {
+	err := Validate([]byte(`
+tiers:
+  - definition: 1080p
+    bitrate: 3500_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libx265
+  - definition: 1080p
+    bitrate: 4500_000
+    audio_bitrate: 160k
+    width: 1920
+    height: 1080
+    codec: libx265
+    hdr: true
+  - definition: 720p
+    bitrate: 2500_000
+    audio_bitrate: 128k
+    width: 1280
+    height: 720
+    hdr: true
+`))
+	var verrs ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, ValidationErrors{
+		{Tier: 2, Field: "hdr", Msg: "codec h264 cannot preserve HDR"},
+	}, verrs)
+}
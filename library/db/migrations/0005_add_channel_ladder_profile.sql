@@ -0,0 +1,8 @@
+-- +migrate Up
+
+ALTER TABLE channels
+    ADD COLUMN ladder_profile text;
+
+-- +migrate Down
+ALTER TABLE channels
+    DROP COLUMN ladder_profile;
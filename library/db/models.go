@@ -58,11 +58,12 @@ func (ns NullChannelPriority) Value() (driver.Value, error) {
 }
 
 type Channel struct {
-	ID        int32
-	CreatedAt time.Time
-	URL       string
-	ClaimID   string
-	Priority  ChannelPriority
+	ID            int32
+	CreatedAt     time.Time
+	URL           string
+	ClaimID       string
+	Priority      ChannelPriority
+	LadderProfile sql.NullString
 }
 
 type Video struct {
@@ -46,3 +46,13 @@ WHERE claim_id = $1;
 
 -- name: GetAllChannels :many
 SELECT * from channels;
+
+-- name: SaveChannel :one
+INSERT INTO channels (
+    url, claim_id, priority, ladder_profile
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (claim_id) DO UPDATE
+SET ladder_profile = EXCLUDED.ladder_profile
+RETURNING *, (xmax = 0)::bool AS inserted;
@@ -8,6 +8,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/sqlc-dev/pqtype"
 )
@@ -18,7 +19,7 @@ INSERT into channels (
 ) VALUES (
     $1, $2, $3
 )
-RETURNING id, created_at, url, claim_id, priority
+RETURNING id, created_at, url, claim_id, priority, ladder_profile
 `
 
 type AddChannelParams struct {
@@ -36,6 +37,7 @@ func (q *Queries) AddChannel(ctx context.Context, arg AddChannelParams) (Channel
 		&i.URL,
 		&i.ClaimID,
 		&i.Priority,
+		&i.LadderProfile,
 	)
 	return i, err
 }
@@ -107,7 +109,7 @@ func (q *Queries) DeleteVideo(ctx context.Context, tid string) error {
 }
 
 const getAllChannels = `-- name: GetAllChannels :many
-SELECT id, created_at, url, claim_id, priority from channels
+SELECT id, created_at, url, claim_id, priority, ladder_profile from channels
 `
 
 func (q *Queries) GetAllChannels(ctx context.Context) ([]Channel, error) {
@@ -125,6 +127,7 @@ func (q *Queries) GetAllChannels(ctx context.Context) ([]Channel, error) {
 			&i.URL,
 			&i.ClaimID,
 			&i.Priority,
+			&i.LadderProfile,
 		); err != nil {
 			return nil, err
 		}
@@ -279,7 +282,7 @@ func (q *Queries) GetAllVideosForStorageLimit(ctx context.Context, arg GetAllVid
 }
 
 const getChannel = `-- name: GetChannel :one
-SELECT id, created_at, url, claim_id, priority from channels
+SELECT id, created_at, url, claim_id, priority, ladder_profile from channels
 WHERE claim_id = $1
 `
 
@@ -292,6 +295,7 @@ func (q *Queries) GetChannel(ctx context.Context, claimID string) (Channel, erro
 		&i.URL,
 		&i.ClaimID,
 		&i.Priority,
+		&i.LadderProfile,
 	)
 	return i, err
 }
@@ -334,3 +338,51 @@ func (q *Queries) RecordVideoAccess(ctx context.Context, sdHash string) error {
 	_, err := q.db.ExecContext(ctx, recordVideoAccess, sdHash)
 	return err
 }
+
+const saveChannel = `-- name: SaveChannel :one
+INSERT INTO channels (
+    url, claim_id, priority, ladder_profile
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (claim_id) DO UPDATE
+SET ladder_profile = EXCLUDED.ladder_profile
+RETURNING id, created_at, url, claim_id, priority, ladder_profile, (xmax = 0)::bool AS inserted
+`
+
+type SaveChannelParams struct {
+	URL           string
+	ClaimID       string
+	Priority      ChannelPriority
+	LadderProfile sql.NullString
+}
+
+type SaveChannelRow struct {
+	ID            int32
+	CreatedAt     time.Time
+	URL           string
+	ClaimID       string
+	Priority      ChannelPriority
+	LadderProfile sql.NullString
+	Inserted      bool
+}
+
+func (q *Queries) SaveChannel(ctx context.Context, arg SaveChannelParams) (SaveChannelRow, error) {
+	row := q.db.QueryRowContext(ctx, saveChannel,
+		arg.URL,
+		arg.ClaimID,
+		arg.Priority,
+		arg.LadderProfile,
+	)
+	var i SaveChannelRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.URL,
+		&i.ClaimID,
+		&i.Priority,
+		&i.LadderProfile,
+		&i.Inserted,
+	)
+	return i, err
+}
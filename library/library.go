@@ -12,6 +12,7 @@ import (
 	"github.com/OdyseeTeam/transcoder/pkg/resolve"
 
 	"github.com/c2h5oh/datasize"
+	ljsonrpc "github.com/lbryio/lbry.go/v2/extras/jsonrpc"
 	"github.com/panjf2000/ants/v2"
 	"github.com/pkg/errors"
 	"github.com/sqlc-dev/pqtype"
@@ -21,7 +22,11 @@ const (
 	SchemeRemote = "remote"
 )
 
-var ErrStreamNotFound = errors.New("stream not found")
+var (
+	ErrStreamNotFound = errors.New("stream not found")
+	ErrNoDASH         = errors.New("stream has no DASH manifest")
+	ErrNoProgressive  = errors.New("stream has no progressive MP4 rendition")
+)
 
 type Storage interface {
 	Name() string
@@ -57,20 +62,56 @@ func (lib *Library) GetVideo(sdHash string) (db.Video, error) {
 }
 
 func (lib *Library) GetVideoURL(sdHash string) (string, error) {
-	var url string
-	v, err := lib.db.GetVideo(context.Background(), sdHash)
+	v, err := lib.accessVideo(sdHash)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return "", ErrStreamNotFound
-		}
 		return "", err
 	}
-	err = lib.db.RecordVideoAccess(context.Background(), v.SDHash)
+	return videoURL(v), nil
+}
+
+// GetDASHURL returns URL of the stream DASH manifest, ErrNoDASH if the stream was transcoded without one.
+func (lib *Library) GetDASHURL(sdHash string) (string, error) {
+	return lib.getFileURL(sdHash, DASHManifestName, func(m *Manifest) bool { return m.DASH }, ErrNoDASH)
+}
+
+// GetProgressiveURL returns URL of the stream progressive MP4 rendition,
+// ErrNoProgressive if the stream was transcoded without one.
+func (lib *Library) GetProgressiveURL(sdHash string) (string, error) {
+	return lib.getFileURL(sdHash, ProgressiveName, func(m *Manifest) bool { return m.Progressive }, ErrNoProgressive)
+}
+
+// getFileURL returns URL of an optional stream file, errMissing if the stream manifest says it does not have one.
+func (lib *Library) getFileURL(sdHash, name string, has func(*Manifest) bool, errMissing error) (string, error) {
+	v, err := lib.accessVideo(sdHash)
 	if err != nil {
 		return "", err
 	}
-	url = fmt.Sprintf("%s://%s/%s/", SchemeRemote, v.Storage, v.Path)
-	return url, nil
+	m := &Manifest{}
+	if v.Manifest.Valid {
+		if err := json.Unmarshal(v.Manifest.RawMessage, m); err != nil {
+			return "", errors.Wrap(err, "cannot unmarshal stream manifest")
+		}
+	}
+	if !has(m) {
+		return "", errMissing
+	}
+	return videoURL(v) + name, nil
+}
+
+// accessVideo retrieves video by its SD hash and records the access.
+func (lib *Library) accessVideo(sdHash string) (db.Video, error) {
+	v, err := lib.db.GetVideo(context.Background(), sdHash)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return v, ErrStreamNotFound
+		}
+		return v, err
+	}
+	return v, lib.db.RecordVideoAccess(context.Background(), v.SDHash)
+}
+
+func videoURL(v db.Video) string {
+	return fmt.Sprintf("%s://%s/%s/", SchemeRemote, v.Storage, v.Path)
 }
 
 func (lib *Library) AddRemoteStream(stream Stream) error {
@@ -101,13 +142,9 @@ func (lib *Library) AddRemoteStream(stream Stream) error {
 }
 
 func (lib *Library) AddChannel(uri string, priority db.ChannelPriority) (db.Channel, error) {
-	var c db.Channel
-	claim, err := resolve.Resolve(uri)
+	claim, err := resolveChannel(uri)
 	if err != nil {
-		return c, err
-	}
-	if claim.ClaimID == "" {
-		return c, errors.New("channel not found")
+		return db.Channel{}, err
 	}
 
 	if priority == "" {
@@ -120,6 +157,48 @@ func (lib *Library) AddChannel(uri string, priority db.ChannelPriority) (db.Chan
 	})
 }
 
+// SaveChannel adds the channel with a named ladder profile or, if it was added before, only assigns the profile to it,
+// leaving its priority as is. Empty profile resets it to the default. The returned flag is set for newly added channels.
+func (lib *Library) SaveChannel(uri string, priority db.ChannelPriority, profile string) (db.Channel, bool, error) {
+	claim, err := resolveChannel(uri)
+	if err != nil {
+		return db.Channel{}, false, err
+	}
+
+	if priority == "" {
+		priority = db.ChannelPriorityNormal
+	}
+	r, err := lib.db.SaveChannel(context.Background(), db.SaveChannelParams{
+		URL:           claim.CanonicalURL,
+		ClaimID:       claim.ClaimID,
+		Priority:      priority,
+		LadderProfile: sql.NullString{String: profile, Valid: profile != ""},
+	})
+	if err != nil {
+		return db.Channel{}, false, err
+	}
+	c := db.Channel{
+		ID:            r.ID,
+		CreatedAt:     r.CreatedAt,
+		URL:           r.URL,
+		ClaimID:       r.ClaimID,
+		Priority:      r.Priority,
+		LadderProfile: r.LadderProfile,
+	}
+	return c, r.Inserted, nil
+}
+
+func resolveChannel(uri string) (*ljsonrpc.Claim, error) {
+	claim, err := resolve.Resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	if claim.ClaimID == "" {
+		return nil, errors.New("channel not found")
+	}
+	return claim, nil
+}
+
 func (lib *Library) GetAllChannels() ([]db.Channel, error) {
 	return lib.db.GetAllChannels(context.Background())
 }
@@ -38,6 +38,32 @@ func (s *librarySuite) TestAddChannel() {
 	s.Equal(db.ChannelPriorityNormal, c.Priority)
 }
 
+func (s *librarySuite) TestSaveChannel() {
+	lib := New(Config{DB: s.DB, Log: zapadapter.NewKV(nil)})
+	c, created, err := lib.SaveChannel("lbry://@specialoperationstest#3", db.ChannelPriorityHigh, "anime")
+	s.Require().NoError(err)
+	s.True(created)
+	s.Equal("395b0f23dcd07212c3e956b697ba5ba89578ca54", c.ClaimID)
+	s.Equal(db.ChannelPriorityHigh, c.Priority)
+	s.Equal("anime", c.LadderProfile.String)
+
+	c, created, err = lib.SaveChannel("lbry://@specialoperationstest#3", db.ChannelPriorityLow, "low-cost")
+	s.Require().NoError(err)
+	s.False(created)
+	s.Equal(db.ChannelPriorityHigh, c.Priority)
+	s.Equal("low-cost", c.LadderProfile.String)
+
+	c, created, err = lib.SaveChannel("lbry://@specialoperationstest#3", "", "")
+	s.Require().NoError(err)
+	s.False(created)
+	s.False(c.LadderProfile.Valid)
+
+	channels, err := lib.GetAllChannels()
+	s.Require().NoError(err)
+	s.Require().Len(channels, 1)
+	s.False(channels[0].LadderProfile.Valid)
+}
+
 func (s *librarySuite) TestAddGetVideo() {
 	var err error
 
@@ -21,14 +21,31 @@ import (
 
 const (
 	MasterPlaylistName  = "master.m3u8"
+	DASHManifestName    = "manifest.mpd"
+	ProgressiveName     = "video.mp4"
 	PlaylistExt         = ".m3u8"
 	FragmentExt         = ".ts"
+	FMP4FragmentExt     = ".m4s"
+	InitSegmentExt      = ".mp4"
+	SubtitleExt         = ".vtt"
+	DASHExt             = ".mpd"
+	SpriteExt           = ".jpg"
+	WebPExt             = ".webp"
 	ManifestName        = ".manifest"
 	PlaylistContentType = "application/x-mpegurl"
 	FragmentContentType = "video/mp2t"
+	SubtitleContentType = "text/vtt"
+
+	FMP4FragmentContentType = "video/iso.segment"
+	InitSegmentContentType  = "video/mp4"
+	DASHContentType         = "application/dash+xml"
+	SpriteContentType       = "image/jpeg"
+	WebPContentType         = "image/webp"
 
 	SkipChecksum = "SkipChecksumForThisStream"
 
+	CodecH264 = "h264"
+
 	tidTimestampFormat = "2006-01-02T15:04"
 )
 
@@ -58,6 +75,34 @@ type Manifest struct {
 
 	FfmpegArgs string   `yaml:"ffmpeg_args,omitempty"`
 	Files      []string `yaml:",omitempty"`
+	// Codecs contains video codec families (h264, hevc, av1) the stream has renditions for.
+	Codecs []string `yaml:",omitempty" json:"codecs,omitempty"`
+	// Complexity is set when ladder was tuned by the content complexity probe.
+	Complexity *Complexity `yaml:",omitempty" json:"complexity,omitempty"`
+	// AudioOnly is set for streams transcoded from sources without video, they contain no video renditions.
+	AudioOnly bool `yaml:"audio_only,omitempty" json:"audio_only,omitempty"`
+	// DASH is set for streams which have MPEG-DASH manifest, it is auto-filled from the file list.
+	DASH bool `yaml:",omitempty" json:"dash,omitempty"`
+	// Progressive is set for streams which have a faststart MP4 rendition, it is auto-filled from the file list.
+	Progressive bool `yaml:",omitempty" json:"progressive,omitempty"`
+	// Quality contains objective quality scores of renditions, if they were measured.
+	Quality []Quality `yaml:",omitempty" json:"quality,omitempty"`
+}
+
+// Complexity contains content complexity probe results.
+type Complexity struct {
+	SampleBitrate int     `yaml:"sample_bitrate" json:"sample_bitrate"`
+	Factor        float64 `json:"factor"`
+}
+
+// Quality contains objective quality scores of a rendition measured against the source.
+type Quality struct {
+	Definition string  `json:"definition"`
+	Codec      string  `json:"codec"`
+	SSIM       float64 `json:"ssim"`
+	PSNR       float64 `json:"psnr"`
+	// VMAF is zero if ffmpeg could not measure it.
+	VMAF float64 `yaml:",omitempty" json:"vmaf,omitempty"`
 }
 
 type StreamWalker func(fi fs.FileInfo, fullPath, name string) error
@@ -86,6 +131,30 @@ func WithVersion(v string) func(*Manifest) {
 	}
 }
 
+func WithCodecs(codecs []string) func(*Manifest) {
+	return func(m *Manifest) {
+		m.Codecs = codecs
+	}
+}
+
+func WithComplexity(sampleBitrate int, factor float64) func(*Manifest) {
+	return func(m *Manifest) {
+		m.Complexity = &Complexity{SampleBitrate: sampleBitrate, Factor: factor}
+	}
+}
+
+func WithQuality(q []Quality) func(*Manifest) {
+	return func(m *Manifest) {
+		m.Quality = q
+	}
+}
+
+func WithAudioOnly(audioOnly bool) func(*Manifest) {
+	return func(m *Manifest) {
+		m.AudioOnly = audioOnly
+	}
+}
+
 func GetStreamHasher() hash.Hash {
 	return sha512.New512_224()
 }
@@ -121,6 +190,14 @@ func (s *Stream) GenerateManifest(url, channel, sdHash string, manifestFuncs ...
 	if err != nil {
 		return errors.Wrap(err, "cannot calculate size")
 	}
+	for _, f := range m.Files {
+		switch f {
+		case DASHManifestName:
+			m.DASH = true
+		case ProgressiveName:
+			m.Progressive = true
+		}
+	}
 	m.Checksum, err = s.generateChecksum()
 	if err != nil {
 		return errors.Wrap(err, "cannot calculate checksum")
@@ -133,6 +210,23 @@ func (s *Stream) GenerateManifest(url, channel, sdHash string, manifestFuncs ...
 	return os.WriteFile(path.Join(s.LocalPath, ManifestName), d, 0644) // #nosec G306, we need the file to be publicly readable
 }
 
+// HasCodec tells if the stream contains renditions encoded with codec c.
+// Streams transcoded before codecs were recorded only contain H.264 renditions.
+func (m *Manifest) HasCodec(c string) bool {
+	if m.AudioOnly {
+		return false
+	}
+	if len(m.Codecs) == 0 {
+		return c == CodecH264
+	}
+	for _, mc := range m.Codecs {
+		if mc == c {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Stream) Checksum() string {
 	if s.Manifest == nil {
 		return ""
@@ -238,6 +332,42 @@ func (s *Stream) ReadManifest() error {
 	return nil
 }
 
+// ContentType returns HTTP content type for a stream file, empty string if the file type is not known.
+func ContentType(name string) string {
+	switch path.Ext(name) {
+	case PlaylistExt:
+		return PlaylistContentType
+	case FragmentExt:
+		return FragmentContentType
+	case FMP4FragmentExt:
+		return FMP4FragmentContentType
+	case InitSegmentExt:
+		// Also covers progressive MP4 rendition and MP4 preview clip.
+		return InitSegmentContentType
+	case SubtitleExt:
+		return SubtitleContentType
+	case DASHExt:
+		return DASHContentType
+	case SpriteExt:
+		// Also covers poster frame.
+		return SpriteContentType
+	case WebPExt:
+		return WebPContentType
+	default:
+		return ""
+	}
+}
+
+// isSegment tells if the file is a media segment or an fMP4 initialization segment.
+func isSegment(name string) bool {
+	switch path.Ext(name) {
+	case FragmentExt, FMP4FragmentExt, InitSegmentExt:
+		return true
+	default:
+		return false
+	}
+}
+
 func openFile(rootPath ...string) (io.ReadCloser, error) {
 	return os.Open(path.Join(rootPath...))
 }
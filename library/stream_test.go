@@ -74,4 +74,42 @@ func TestStream(t *testing.T) {
 		assert.Equal(t, channelURL, stream.Manifest.ChannelURL)
 		assert.Equal(t, sdHash, stream.Manifest.SDHash)
 	})
+
+	t.Run("WithCodecs", func(t *testing.T) {
+		t.Parallel()
+
+		stream := InitStream(path.Join(dir, sdHash), "")
+		require.NoError(t,
+			stream.GenerateManifest(randomdata.SillyName(), randomdata.SillyName(), sdHash, WithCodecs([]string{"h264", "av1"})),
+		)
+		assert.Equal(t, []string{"h264", "av1"}, stream.Manifest.Codecs)
+		assert.True(t, stream.Manifest.HasCodec("av1"))
+		assert.False(t, stream.Manifest.HasCodec("hevc"))
+
+		legacy := &Manifest{}
+		assert.True(t, legacy.HasCodec(CodecH264))
+		assert.False(t, legacy.HasCodec("av1"))
+
+		audio := &Manifest{AudioOnly: true}
+		assert.False(t, audio.HasCodec(CodecH264))
+	})
+
+	t.Run("DASH", func(t *testing.T) {
+		t.Parallel()
+
+		stream := InitStream(path.Join(dir, sdHash), "")
+		require.NoError(t, stream.GenerateManifest(randomdata.SillyName(), randomdata.SillyName(), sdHash))
+		assert.False(t, stream.Manifest.DASH)
+		assert.False(t, stream.Manifest.Progressive)
+	})
+}
+
+func TestContentType(t *testing.T) {
+	assert.Equal(t, DASHContentType, ContentType(DASHManifestName))
+	assert.Equal(t, FMP4FragmentContentType, ContentType("v0_s000001.m4s"))
+	assert.Equal(t, "video/mp4", ContentType(ProgressiveName))
+	assert.Equal(t, SpriteContentType, ContentType("stream_0.jpg"))
+	assert.Equal(t, SpriteContentType, ContentType("poster.jpg"))
+	assert.Equal(t, WebPContentType, ContentType("preview.webp"))
+	assert.Equal(t, "", ContentType("notes.txt"))
 }
@@ -3,7 +3,6 @@ package library
 import (
 	"io"
 	"net/http"
-	"path"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -29,7 +28,7 @@ func ValidateStream(baseURL string, failFast bool, skipSegments bool) (*Validati
 				err error
 			)
 			url := strings.Join(p, "/")
-			if path.Ext(p[len(p)-1]) == ".ts" {
+			if isSegment(p[len(p)-1]) {
 				if skipSegments {
 					return nil, ErrSkipSegment
 				}
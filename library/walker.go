@@ -53,36 +53,74 @@ func WalkStream(baseURI string, getFn StreamGetter, processFn StreamProcessor) e
 	}
 
 	masterpl := pl.(*m3u8.MasterPlaylist)
+	walked := map[string]bool{}
 	for _, varpl := range masterpl.Variants {
-		p, err := parsePlaylist(varpl.URI)
-		if err != nil {
-			return err
-		}
-		mediapl := p.(*m3u8.MediaPlaylist)
-
-		for _, seg := range mediapl.Segments {
-			if seg == nil {
-				continue
+		uris := []string{varpl.URI}
+		// Audio and subtitle renditions shared by variants are listed separately in EXT-X-MEDIA tags.
+		for _, alt := range varpl.Alternatives {
+			if alt != nil && alt.URI != "" {
+				uris = append(uris, alt.URI)
 			}
-			r, err := getFn(baseURI, seg.URI)
-			if errors.Is(err, ErrSkipSegment) {
+		}
+		for _, uri := range uris {
+			if walked[uri] {
 				continue
 			}
-			if err != nil {
-				return fmt.Errorf("error getting stream item %v: %w", seg.URI, err)
-			}
-			err = processFn(seg.URI, r)
-			if r != nil {
-				r.Close()
-			}
-			if err != nil {
-				return fmt.Errorf("error processing stream item %v: %w", varpl.URI, err)
+			walked[uri] = true
+			if err := walkMediaPlaylist(baseURI, uri, getFn, processFn, parsePlaylist); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
 
+func walkMediaPlaylist(
+	baseURI, uri string, getFn StreamGetter, processFn StreamProcessor, parsePlaylist func(string) (m3u8.Playlist, error),
+) error {
+	p, err := parsePlaylist(uri)
+	if err != nil {
+		return err
+	}
+	mediapl := p.(*m3u8.MediaPlaylist)
+
+	// fMP4 playlists reference initialization segments in EXT-X-MAP tags, usually once per playlist.
+	segURIs := []string{}
+	inits := map[string]bool{}
+	addInit := func(m *m3u8.Map) {
+		if m != nil && m.URI != "" && !inits[m.URI] {
+			inits[m.URI] = true
+			segURIs = append(segURIs, m.URI)
+		}
+	}
+	addInit(mediapl.Map)
+	for _, seg := range mediapl.Segments {
+		if seg == nil {
+			continue
+		}
+		addInit(seg.Map)
+		segURIs = append(segURIs, seg.URI)
+	}
+
+	for _, segURI := range segURIs {
+		r, err := getFn(baseURI, segURI)
+		if errors.Is(err, ErrSkipSegment) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error getting stream item %v: %w", segURI, err)
+		}
+		err = processFn(segURI, r)
+		if r != nil {
+			r.Close()
+		}
+		if err != nil {
+			return fmt.Errorf("error processing stream item %v: %w", uri, err)
+		}
+	}
+	return nil
+}
+
 func read(r io.ReadCloser) (io.ReadSeeker, error) {
 	d, err := io.ReadAll(r)
 	r.Close()
@@ -0,0 +1,116 @@
+package library
+
+import (
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalkStreamRenditions(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		MasterPlaylistName: `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="group_audio",NAME="audio_0",DEFAULT=YES,URI="v2.m3u8"
+#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subtitles",NAME="en",LANGUAGE="en",DEFAULT=NO,AUTOSELECT=YES,FORCED=NO,URI="sub0.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2",AUDIO="group_audio",SUBTITLES="subtitles"
+v0.m3u8
+
+#EXT-X-STREAM-INF:BANDWIDTH=600000,RESOLUTION=640x360,CODECS="avc1.4d401e,mp4a.40.2",AUDIO="group_audio",SUBTITLES="subtitles"
+v1.m3u8
+`,
+		"v0.m3u8":   mediaPlaylist("v0_s000000.ts"),
+		"v1.m3u8":   mediaPlaylist("v1_s000000.ts"),
+		"v2.m3u8":   mediaPlaylist("v2_s000000.ts"),
+		"sub0.m3u8": mediaPlaylist("sub0_s000000.vtt"),
+	}
+	for n, c := range files {
+		require.NoError(t, os.WriteFile(path.Join(dir, n), []byte(c), 0600))
+	}
+	for _, n := range []string{"v0_s000000.ts", "v1_s000000.ts", "v2_s000000.ts", "sub0_s000000.vtt"} {
+		require.NoError(t, os.WriteFile(path.Join(dir, n), []byte("segment"), 0600))
+	}
+
+	walked := []string{}
+	err := WalkStream(dir,
+		func(p ...string) (io.ReadCloser, error) {
+			return os.Open(path.Join(p...))
+		},
+		func(name string, r io.ReadCloser) error {
+			walked = append(walked, name)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		MasterPlaylistName,
+		"v0.m3u8", "v0_s000000.ts",
+		"v2.m3u8", "v2_s000000.ts",
+		"sub0.m3u8", "sub0_s000000.vtt",
+		"v1.m3u8", "v1_s000000.ts",
+	}, walked)
+}
+
+func mediaPlaylist(segment string) string {
+	return `#EXTM3U
+#EXT-X-VERSION:3
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXTINF:10.000000,
+` + segment + `
+#EXT-X-ENDLIST
+`
+}
+
+func TestWalkStreamFMP4(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		MasterPlaylistName: `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-STREAM-INF:BANDWIDTH=1400000,RESOLUTION=1280x720,CODECS="avc1.4d401f,mp4a.40.2"
+v0.m3u8
+`,
+		"v0.m3u8": `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:10
+#EXT-X-MEDIA-SEQUENCE:0
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-MAP:URI="v0_init.mp4"
+#EXTINF:10.000000,
+v0_s000000.m4s
+#EXTINF:10.000000,
+v0_s000001.m4s
+#EXT-X-ENDLIST
+`,
+		"v0_init.mp4":    "init",
+		"v0_s000000.m4s": "segment",
+		"v0_s000001.m4s": "segment",
+	}
+	for n, c := range files {
+		require.NoError(t, os.WriteFile(path.Join(dir, n), []byte(c), 0600))
+	}
+
+	walked := []string{}
+	err := WalkStream(dir,
+		func(p ...string) (io.ReadCloser, error) {
+			return os.Open(path.Join(p...))
+		},
+		func(name string, r io.ReadCloser) error {
+			walked = append(walked, name)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		MasterPlaylistName, "v0.m3u8", "v0_init.mp4", "v0_s000000.m4s", "v0_s000001.m4s",
+	}, walked)
+
+	assert.Equal(t, FMP4FragmentContentType, ContentType("v0_s000000.m4s"))
+	assert.Equal(t, InitSegmentContentType, ContentType("v0_init.mp4"))
+	assert.Equal(t, "", ContentType(ManifestName))
+}
@@ -10,13 +10,13 @@ import (
 
 type channelList struct {
 	sync.Mutex
-	items map[string]db.ChannelPriority
+	items map[string]db.Channel
 }
 
 func newChannelList() *channelList {
 	return &channelList{
 		Mutex: sync.Mutex{},
-		items: map[string]db.ChannelPriority{},
+		items: map[string]db.Channel{},
 	}
 }
 
@@ -34,7 +34,7 @@ func (c *channelList) Load(channels []db.Channel) {
 	c.Lock()
 	defer c.Unlock()
 	for _, ch := range channels {
-		c.items[ch.ClaimID] = ch.Priority
+		c.items[ch.ClaimID] = ch
 	}
 }
 
@@ -44,6 +44,13 @@ func (c *channelList) GetPriority(r *TranscodingRequest) db.ChannelPriority {
 	if ch, ok := c.items[r.ChannelClaimID]; !ok {
 		return db.ChannelPriorityLow
 	} else {
-		return ch
+		return ch.Priority
 	}
 }
+
+// GetLadderProfile returns the name of ladder profile assigned to the request channel, empty if none.
+func (c *channelList) GetLadderProfile(r *TranscodingRequest) string {
+	c.Lock()
+	defer c.Unlock()
+	return c.items[r.ChannelClaimID].LadderProfile.String
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/OdyseeTeam/transcoder/internal/metrics"
+	"github.com/OdyseeTeam/transcoder/library"
 	"github.com/OdyseeTeam/transcoder/library/db"
 	"github.com/OdyseeTeam/transcoder/pkg/dispatcher"
 	"github.com/OdyseeTeam/transcoder/pkg/logging"
@@ -24,6 +25,7 @@ const (
 	AuthHeader        = "Authorization"
 	AuthTokenPrefix   = "Bearer "
 	AdminChannelField = "channel"
+	AdminProfileField = "ladder_profile"
 )
 
 type AuthCallback func(*fasthttp.RequestCtx) bool
@@ -43,6 +45,8 @@ func CreateRoutes(r *router.Router, manager *VideoManager, log logging.KVLogger,
 	}
 
 	r.GET("/api/v1/video/{kind:hls}/{url}", h.handleVideo)
+	r.GET("/api/v1/video/{kind:dash}/{url}", h.handleVideo)
+	r.GET("/api/v1/video/{kind:mp4}/{url}", h.handleVideo)
 	r.GET("/api/v2/video/{url}", h.handleVideo)
 	r.GET("/api/v3/video", h.handleVideo) // accepts URL as a query param
 
@@ -85,7 +89,14 @@ func (h httpVideoHandler) handleVideo(ctx *fasthttp.RequestCtx) {
 		"path", path,
 	)
 
-	location, err := h.manager.GetVideoURL(videoURL)
+	getURL := h.manager.GetVideoURL
+	switch ctx.UserValue("kind") {
+	case "dash":
+		getURL = h.manager.GetDASHURL
+	case "mp4":
+		getURL = h.manager.GetProgressiveURL
+	}
+	location, err := getURL(videoURL)
 
 	if err != nil {
 		var (
@@ -106,6 +117,8 @@ func (h httpVideoHandler) handleVideo(ctx *fasthttp.RequestCtx) {
 		case resolve.ErrClaimNotFound:
 			statusCode = http.StatusNotFound
 			ll.Info("claim not found")
+		case library.ErrNoDASH, library.ErrNoProgressive:
+			statusCode = http.StatusNotFound
 		default:
 			statusCode = http.StatusInternalServerError
 			ll.Errorw("internal error", "err", err)
@@ -149,16 +162,43 @@ func (h httpVideoHandler) handleChannel(ctx *fasthttp.RequestCtx) {
 		fmt.Fprint(ctx, "channel missing")
 		return
 	}
+	profile := string(ctx.FormValue(AdminProfileField))
+	if _, ok := h.manager.profiles.Get(profile); !ok {
+		ctx.SetStatusCode(http.StatusBadRequest)
+		fmt.Fprintf(ctx, "unknown ladder profile %q, available profiles: %s", profile, strings.Join(h.manager.profiles.Names(), ", "))
+		return
+	}
 	var priority db.ChannelPriority
 	priority.Scan(ctx.FormValue("priority"))
-	c, err := h.manager.lib.AddChannel(channel, priority)
+
+	// When a ladder profile is sent, channels which already exist only get it updated, an empty one resetting it to the default.
+	var (
+		c       db.Channel
+		created = true
+		err     error
+	)
+	if ctx.PostArgs().Has(AdminProfileField) || ctx.QueryArgs().Has(AdminProfileField) {
+		c, created, err = h.manager.lib.SaveChannel(channel, priority, profile)
+	} else {
+		c, err = h.manager.lib.AddChannel(channel, priority)
+	}
 	if err != nil {
 		ctx.SetStatusCode(http.StatusBadRequest)
 		fmt.Fprint(ctx, err.Error())
 		return
 	}
-	ctx.SetStatusCode(http.StatusCreated)
-	fmt.Fprintf(ctx, "channel %s (%s) added with priority %s", c.URL, c.ClaimID, c.Priority)
+	switch {
+	case !created && c.LadderProfile.Valid:
+		fmt.Fprintf(ctx, "channel %s (%s) ladder profile set to %s", c.URL, c.ClaimID, c.LadderProfile.String)
+	case !created:
+		fmt.Fprintf(ctx, "channel %s (%s) ladder profile reset to default", c.URL, c.ClaimID)
+	default:
+		ctx.SetStatusCode(http.StatusCreated)
+		fmt.Fprintf(ctx, "channel %s (%s) added with priority %s", c.URL, c.ClaimID, c.Priority)
+		if c.LadderProfile.Valid {
+			fmt.Fprintf(ctx, " and ladder profile %s", c.LadderProfile.String)
+		}
+	}
 }
 
 func CORSMiddleware(h fasthttp.RequestHandler) fasthttp.RequestHandler {
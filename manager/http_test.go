@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/library"
 	db "github.com/OdyseeTeam/transcoder/library/db"
 	"github.com/OdyseeTeam/transcoder/pkg/logging/zapadapter"
@@ -21,6 +22,7 @@ import (
 	"github.com/fasthttp/router"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/valyala/fasthttp"
 	"github.com/valyala/fasthttp/fasthttputil"
@@ -119,6 +121,9 @@ func (s *httpSuite) TestAdmin() {
 	token := "test-token"
 	lib := library.New(library.Config{DB: s.DB, Log: zapadapter.NewKV(nil)})
 	mgr := NewManager(lib, 0)
+	profiles := ladder.NewProfiles()
+	profiles.Add("low-cost", ladder.Default)
+	mgr.SetLadderProfiles(profiles)
 
 	CreateRoutes(router, mgr, zapadapter.NewKV(nil), func(ctx *fasthttp.RequestCtx) bool {
 		return ctx.UserValue(TokenCtxField).(string) == token
@@ -130,6 +135,12 @@ func (s *httpSuite) TestAdmin() {
 		statusCode                   int
 		exURL, exClaimID, exResponse string
 	}{
+		{
+			data:        url.Values{AdminChannelField: []string{adminChannel}, AdminProfileField: []string{"low-cots"}},
+			tokenHeader: AuthTokenPrefix + token,
+			statusCode:  http.StatusBadRequest,
+			exResponse:  `unknown ladder profile "low-cots", available profiles: default, low-cost`,
+		},
 		{
 			data:        url.Values{AdminChannelField: []string{adminChannel}},
 			tokenHeader: AuthTokenPrefix + token,
@@ -143,6 +154,20 @@ func (s *httpSuite) TestAdmin() {
 			statusCode:  http.StatusBadRequest,
 			exResponse:  `.+duplicate key value violates unique constraint.+`,
 		},
+		{
+			data:        url.Values{AdminChannelField: []string{adminChannel}, AdminProfileField: []string{"low-cost"}},
+			tokenHeader: AuthTokenPrefix + token,
+			statusCode:  http.StatusOK,
+			exURL:       "lbry://@specialoperationstest#3",
+			exResponse:  `ladder profile set to low-cost$`,
+		},
+		{
+			data:        url.Values{AdminChannelField: []string{adminChannel}, AdminProfileField: []string{""}},
+			tokenHeader: AuthTokenPrefix + token,
+			statusCode:  http.StatusOK,
+			exURL:       "lbry://@specialoperationstest#3",
+			exResponse:  `ladder profile reset to default$`,
+		},
 		{
 			data:        url.Values{AdminChannelField: []string{randomdata.Alphanumeric(25)}},
 			tokenHeader: AuthTokenPrefix + token,
@@ -170,12 +195,20 @@ func (s *httpSuite) TestAdmin() {
 
 			channels, err := lib.GetAllChannels()
 			s.Require().NoError(err)
+			if c.statusCode == http.StatusBadRequest && c.data.Has(AdminProfileField) {
+				s.Empty(channels)
+			}
 			if c.exURL != "" {
 				s.Require().Equal(c.exURL, channels[0].URL)
 			}
 			if c.exClaimID != "" {
 				s.Require().Equal(c.exClaimID, channels[0].ClaimID)
 			}
+			if c.statusCode == http.StatusOK {
+				s.Require().Len(channels, 1)
+				s.Equal(c.data.Get(AdminProfileField), channels[0].LadderProfile.String)
+				s.Equal(db.ChannelPriorityNormal, channels[0].Priority)
+			}
 		})
 	}
 
@@ -210,3 +243,19 @@ func (s *httpSuite) createServer() (*router.Router, *http.Client) {
 
 	return router, client
 }
+
+func TestHandleChannelUnknownProfile(t *testing.T) {
+	h := httpVideoHandler{
+		manager:      &VideoManager{profiles: ladder.NewProfiles()},
+		log:          zapadapter.NewKV(nil),
+		authCallback: func(*fasthttp.RequestCtx) bool { return true },
+	}
+	ctx := &fasthttp.RequestCtx{}
+	ctx.Request.Header.SetMethod(http.MethodPost)
+	ctx.Request.Header.SetContentType("application/x-www-form-urlencoded")
+	ctx.Request.SetBodyString(url.Values{AdminChannelField: []string{adminChannel}, AdminProfileField: []string{"../anime"}}.Encode())
+
+	h.handleChannel(ctx)
+	assert.Equal(t, http.StatusBadRequest, ctx.Response.StatusCode())
+	assert.Equal(t, `unknown ladder profile "../anime", available profiles: default`, string(ctx.Response.Body()))
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/library"
 	db "github.com/OdyseeTeam/transcoder/library/db"
 	"github.com/OdyseeTeam/transcoder/pkg/conductor/metrics"
@@ -31,7 +32,9 @@ var (
 
 type TranscodingRequest struct {
 	resolve.ResolvedStream
-	queue *mfr.Queue
+	// LadderProfile is the name of ladder profile the stream should be encoded with, empty for default.
+	LadderProfile string
+	queue         *mfr.Queue
 }
 
 type HttpServerConfig struct {
@@ -55,6 +58,7 @@ type VideoManager struct {
 	cache    *ccache.Cache
 	channels *channelList
 	resolver ResolverFunc
+	profiles *ladder.Profiles
 }
 
 // NewManager creates a video library manager with a pool for future transcoding requests.
@@ -63,6 +67,7 @@ func NewManager(lib *library.Library, minHits uint) *VideoManager {
 		lib:      lib,
 		pool:     NewPool(),
 		channels: newChannelList(),
+		profiles: ladder.NewProfiles(),
 		cache: ccache.New(ccache.
 			Configure().
 			MaxSize(cacheSize)),
@@ -131,6 +136,12 @@ func (m *VideoManager) SetResolver(rf ResolverFunc) {
 	m.resolver = rf
 }
 
+// SetLadderProfiles sets ladder profiles channels can be assigned, which should be the same profiles workers have.
+// Only the default profile is known unless set.
+func (m *VideoManager) SetLadderProfiles(p *ladder.Profiles) {
+	m.profiles = p
+}
+
 func (m *VideoManager) Pool() *Pool {
 	return m.pool
 }
@@ -151,6 +162,22 @@ func (m *VideoManager) Library() *library.Library {
 // Video checks if video exists in the library or waiting in one of the queues.
 // If neither, it adds claim to the pool for later processing.
 func (m *VideoManager) GetVideoURL(uri string) (string, error) {
+	return m.getURL(uri, m.lib.GetVideoURL)
+}
+
+// GetDASHURL works like GetVideoURL but returns DASH manifest location.
+// library.ErrNoDASH is returned for streams transcoded without DASH manifest.
+func (m *VideoManager) GetDASHURL(uri string) (string, error) {
+	return m.getURL(uri, m.lib.GetDASHURL)
+}
+
+// GetProgressiveURL works like GetVideoURL but returns progressive MP4 location.
+// library.ErrNoProgressive is returned for streams transcoded without progressive rendition.
+func (m *VideoManager) GetProgressiveURL(uri string) (string, error) {
+	return m.getURL(uri, m.lib.GetProgressiveURL)
+}
+
+func (m *VideoManager) getURL(uri string, getFn func(sdHash string) (string, error)) (string, error) {
 	uri = strings.TrimPrefix(uri, "lbry://")
 	tcRequest, err := m.ResolveStream(uri)
 	if err != nil {
@@ -161,8 +188,12 @@ func (m *VideoManager) GetVideoURL(uri string) (string, error) {
 		return "", resolve.ErrTranscodingForbidden
 	}
 
-	vloc, err := m.lib.GetVideoURL(tcRequest.SDHash)
+	vloc, err := getFn(tcRequest.SDHash)
+	if err == library.ErrNoDASH || err == library.ErrNoProgressive {
+		return "", err
+	}
 	if err != nil {
+		tcRequest.LadderProfile = m.channels.GetLadderProfile(tcRequest)
 		return "", m.pool.Admit(tcRequest.SDHash, tcRequest)
 	}
 
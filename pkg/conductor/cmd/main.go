@@ -131,6 +131,15 @@ func startConductor() {
 		log.Fatal("min hits cannot be below zero")
 	}
 	mgr := manager.NewManager(lib, uint(cfg.AdaptiveQueue.MinHits)) // nolint:gosec
+	profiles := ladder.NewProfiles()
+	if cfg.LadderProfiles != "" {
+		profiles, err = ladder.LoadProfiles(cfg.LadderProfiles)
+		if err != nil {
+			log.Fatal("ladder profiles loading failed", err)
+		}
+		log.Infow("ladder profiles loaded", "profiles", profiles.Names())
+	}
+	mgr.SetLadderProfiles(profiles)
 
 	httpStopChan, _ := mgr.StartHttpServer(manager.HttpServerConfig{
 		ManagerToken: cfg.Library.ManagerToken,
@@ -141,7 +150,18 @@ func startConductor() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	cnd, err := conductor.NewConductor(redisOpts, mgr.Requests(), lib, conductor.WithLogger(zapadapter.NewKV(log.Desugar())))
+	cndOpts := []func(*conductor.ConductorOptions){
+		conductor.WithLogger(zapadapter.NewKV(log.Desugar())),
+		conductor.WithLadderProfiles(profiles),
+	}
+	if cfg.Chunking.Enabled {
+		if cfg.Chunking.MinDuration <= 0 || cfg.Chunking.Duration <= 0 {
+			log.Fatal("chunking min duration and duration must be positive")
+		}
+		log.Infow("splitting long videos into chunk tasks", "min_duration", cfg.Chunking.MinDuration, "duration", cfg.Chunking.Duration)
+		cndOpts = append(cndOpts, conductor.WithChunking(cfg.Chunking.MinDuration, cfg.Chunking.Duration))
+	}
+	cnd, err := conductor.NewConductor(redisOpts, mgr.Requests(), lib, cndOpts...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -202,7 +222,13 @@ func startWorker() {
 	log.Infow("s3 storage configured", "name", cfg.Storage.Name, "endpoint", cfg.Storage.Endpoint)
 
 	enc, err := encoder.NewEncoder(encoder.Configure().
-		Log(zapadapter.NewKV(log.Desugar())),
+		Log(zapadapter.NewKV(log.Desugar())).
+		Sprites(spriteConfig(cfg.Sprites)).
+		Poster(posterConfig(cfg.Poster)).
+		Preview(previewConfig(cfg.Preview)).
+		Chunks(chunkConfig(cfg.Chunks)).
+		Quality(qualityConfig(cfg.Quality)).
+		InputPolicy(inputPolicy(cfg.InputPolicy)),
 	)
 	if err != nil {
 		log.Fatal("encoder initialization failed", err)
@@ -273,6 +299,15 @@ func startWorker() {
 		runnerOpts = append(runnerOpts, tasks.WithDiskPressure(diskCfg))
 	}
 
+	if cfg.LadderProfiles != "" {
+		profiles, err := ladder.LoadProfiles(cfg.LadderProfiles)
+		if err != nil {
+			log.Fatal("ladder profiles loading failed", err)
+		}
+		log.Infow("ladder profiles loaded", "profiles", profiles.Names())
+		runnerOpts = append(runnerOpts, tasks.WithLadderProfiles(profiles))
+	}
+
 	runner, err := tasks.NewEncoderRunner(
 		storage, enc, tasks.NewResultWriter(redisOpts),
 		runnerOpts...,
@@ -374,3 +409,117 @@ func makeWorkDir(path string) error {
 	}
 	return nil
 }
+
+// spriteConfig applies worker sprite settings on top of encoder defaults.
+func spriteConfig(c config.Sprites) *encoder.SpriteConfig {
+	if c.Disabled {
+		return nil
+	}
+	s := encoder.DefaultSpriteConfig
+	if c.Interval > 0 {
+		s.Interval = c.Interval
+	}
+	if c.Width > 0 {
+		s.Width = c.Width
+	}
+	if c.Columns > 0 {
+		s.Columns = c.Columns
+	}
+	if c.Rows > 0 {
+		s.Rows = c.Rows
+	}
+	return &s
+}
+
+// posterConfig applies worker poster settings on top of encoder defaults.
+func posterConfig(c config.Poster) *encoder.PosterConfig {
+	if c.Disabled {
+		return nil
+	}
+	p := encoder.DefaultPosterConfig
+	if c.Width > 0 {
+		p.Width = c.Width
+	}
+	return &p
+}
+
+// previewConfig applies worker preview settings on top of encoder defaults.
+func previewConfig(c config.Preview) *encoder.PreviewConfig {
+	if !c.Enabled {
+		return nil
+	}
+	p := encoder.DefaultPreviewConfig
+	if c.Format != "" {
+		p.Format = c.Format
+	}
+	if c.Duration > 0 {
+		p.Duration = c.Duration
+	}
+	if c.Width > 0 {
+		p.Width = c.Width
+	}
+	if c.Framerate > 0 {
+		p.Framerate = c.Framerate
+	}
+	return &p
+}
+
+// chunkConfig applies worker chunked encoding settings on top of encoder defaults.
+func chunkConfig(c config.Chunks) *encoder.ChunkConfig {
+	if !c.Enabled {
+		return nil
+	}
+	ch := encoder.DefaultChunkConfig
+	if c.MinDuration > 0 {
+		ch.MinDuration = c.MinDuration
+	}
+	if c.Duration > 0 {
+		ch.Duration = c.Duration
+	}
+	if c.Parallel > 0 {
+		ch.Parallel = c.Parallel
+	}
+	return &ch
+}
+
+// qualityConfig applies worker quality measurement settings on top of encoder defaults.
+func qualityConfig(c config.Quality) *encoder.QualityConfig {
+	if !c.Enabled {
+		return nil
+	}
+	q := encoder.DefaultQualityConfig
+	if c.Samples > 0 {
+		q.Samples = c.Samples
+	}
+	if c.SampleDuration > 0 {
+		q.SampleDuration = c.SampleDuration
+	}
+	q.VMAF = !c.DisableVMAF
+	return &q
+}
+
+// inputPolicy applies worker input limits on top of encoder defaults.
+func inputPolicy(c config.InputPolicy) *encoder.InputPolicy {
+	if c.Disabled {
+		return nil
+	}
+	p := encoder.DefaultInputPolicy
+	if c.MaxDuration > 0 {
+		p.MaxDuration = c.MaxDuration
+	}
+	if c.MaxWidth > 0 {
+		p.MaxWidth = c.MaxWidth
+	}
+	if c.MaxHeight > 0 {
+		p.MaxHeight = c.MaxHeight
+	}
+	if c.MaxFramerate > 0 {
+		p.MaxFramerate = c.MaxFramerate
+	}
+	if c.MaxBitrate > 0 {
+		p.MaxBitrate = c.MaxBitrate
+	}
+	p.VideoCodecs = c.VideoCodecs
+	p.AudioCodecs = c.AudioCodecs
+	return &p
+}
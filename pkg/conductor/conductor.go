@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/library"
 	"github.com/OdyseeTeam/transcoder/manager"
 	"github.com/OdyseeTeam/transcoder/pkg/conductor/metrics"
@@ -26,8 +28,19 @@ type Conductor struct {
 	options        *ConductorOptions
 }
 
+// chunksKeyPrefix is the prefix of redis sets tracking encoded chunks of streams.
+const chunksKeyPrefix = "transcoding:chunks:"
+
 type ConductorOptions struct {
 	Logger logging.KVLogger
+	// ChunkMinDuration is the video duration in seconds starting from which streams are split
+	// into chunk tasks encoded by separate workers, zero disables splitting.
+	ChunkMinDuration int
+	// ChunkDuration is the approximate duration of a chunk in seconds.
+	ChunkDuration int
+	// LadderProfiles are ladder profiles workers encode with, only streams with known profiles
+	// which allow chunked encoding are split.
+	LadderProfiles *ladder.Profiles
 }
 
 func WithLogger(logger logging.KVLogger) func(options *ConductorOptions) {
@@ -36,12 +49,30 @@ func WithLogger(logger logging.KVLogger) func(options *ConductorOptions) {
 	}
 }
 
+// WithChunking enables splitting videos at least minDuration seconds long into chunks of about duration seconds,
+// which are encoded by separate workers and then assembled into a single stream.
+func WithChunking(minDuration, duration int) func(options *ConductorOptions) {
+	return func(options *ConductorOptions) {
+		options.ChunkMinDuration = minDuration
+		options.ChunkDuration = duration
+	}
+}
+
+// WithLadderProfiles sets ladder profiles workers have, which are checked for chunked encoding support.
+// Only the default ladder is known unless set.
+func WithLadderProfiles(p *ladder.Profiles) func(options *ConductorOptions) {
+	return func(options *ConductorOptions) {
+		options.LadderProfiles = p
+	}
+}
+
 func NewConductor(
 	redisOpts asynq.RedisConnOpt, incoming <-chan *manager.TranscodingRequest, library *library.Library,
 	optionFuncs ...func(*ConductorOptions),
 ) (*Conductor, error) {
 	options := &ConductorOptions{
-		Logger: logging.NoopKVLogger{},
+		Logger:         logging.NoopKVLogger{},
+		LadderProfiles: ladder.NewProfiles(),
 	}
 	for _, optionFunc := range optionFuncs {
 		optionFunc(options)
@@ -77,6 +108,8 @@ func StartWorker(redisOpts asynq.RedisConnOpt, concurrency int, runner *tasks.En
 	// mux maps a type to a handler
 	mux := asynq.NewServeMux()
 	mux.HandleFunc(tasks.TypeTranscodingRequest, runner.Run)
+	mux.HandleFunc(tasks.TypeChunkRequest, runner.RunChunk)
+	mux.HandleFunc(tasks.TypeAssemblyRequest, runner.RunAssembly)
 
 	if err := srv.Run(mux); err != nil {
 		log.Fatal("could not run server: %v", err)
@@ -149,7 +182,11 @@ func (c *Conductor) DispatchNextTask() error {
 	trReq := <-c.incoming
 	req.URL = trReq.URI
 	req.SDHash = trReq.SDHash
+	req.LadderProfile = trReq.LadderProfile
 	logger := c.options.Logger.With("url", req.URL, "sd_hash", req.SDHash)
+	if c.options.splittable(trReq.Duration, req.LadderProfile, logger) {
+		return c.dispatchChunks(*req, trReq.Duration, logger)
+	}
 	t, err := tasks.NewTranscodingTask(*req)
 	if err != nil {
 		return fmt.Errorf("task creation error: %w", err)
@@ -172,6 +209,69 @@ func (c *Conductor) DispatchNextTask() error {
 	return nil
 }
 
+// splittable tells if a video of duration seconds to be encoded with the ladder profile should be split into chunk tasks.
+// Ladders with fMP4 segments cannot be encoded in chunks, neither can unknown ones since that cannot be checked.
+func (o ConductorOptions) splittable(duration int, profile string, logger logging.KVLogger) bool {
+	if o.ChunkMinDuration <= 0 || o.ChunkDuration <= 0 || duration < o.ChunkMinDuration {
+		return false
+	}
+	l, ok := o.LadderProfiles.Get(profile)
+	switch {
+	case !ok:
+		logger.Info("not splitting, ladder profile is unknown", "profile", profile)
+		return false
+	case l.FMP4():
+		logger.Info("not splitting, ladder profile uses fMP4 segments", "profile", profile)
+		return false
+	}
+	return true
+}
+
+// dispatchChunks splits the video into time ranges of about ChunkDuration seconds and enqueues a task for each.
+func (c *Conductor) dispatchChunks(req tasks.TranscodingRequest, duration int, logger logging.KVLogger) error {
+	reqs := splitRequest(req, duration, c.options.ChunkDuration)
+	for _, cr := range reqs {
+		t, err := tasks.NewChunkTask(cr)
+		if err != nil {
+			return fmt.Errorf("task creation error: %w", err)
+		}
+		info, err := c.asynqClient.Enqueue(
+			t,
+			asynq.Unique(24*time.Hour),
+			asynq.Timeout(24*time.Hour),
+			asynq.Retention(72*time.Hour),
+		)
+		if errors.Is(err, asynq.ErrDuplicateTask) && cr.Index == 0 {
+			logger.Info("task deemed duplicate, skipping")
+			return c.DispatchNextTask()
+		}
+		if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+			return fmt.Errorf("task enqueue error: %w", err)
+		}
+		if info != nil {
+			logger.Info("enqueued chunk task", "tid", info.ID, "queue", info.Queue, "chunk", cr.Index, "chunks", cr.Count)
+		}
+	}
+	return nil
+}
+
+// splitRequest splits request for a video of duration seconds into chunk requests of about chunkDuration seconds.
+func splitRequest(req tasks.TranscodingRequest, duration, chunkDuration int) []tasks.ChunkRequest {
+	n := int(math.Round(float64(duration) / float64(chunkDuration)))
+	if n < 1 {
+		n = 1
+	}
+	step := float64(duration) / float64(n)
+	reqs := make([]tasks.ChunkRequest, n)
+	for i := range reqs {
+		reqs[i] = tasks.ChunkRequest{TranscodingRequest: req, Index: i, Count: n, Start: float64(i) * step}
+		if i < n-1 {
+			reqs[i].End = float64(i+1) * step
+		}
+	}
+	return reqs
+}
+
 func (c *Conductor) ProcessNextResult() error {
 	res := &tasks.TranscodingResult{}
 	r, err := c.rdb.BLPop(context.Background(), 0, tasks.QueueTranscodingResults).Result()
@@ -183,6 +283,9 @@ func (c *Conductor) ProcessNextResult() error {
 	if err != nil {
 		return fmt.Errorf("message parsing error: %w", err)
 	}
+	if res.Chunk != nil {
+		return c.processChunk(*res.Chunk)
+	}
 	logger := c.options.Logger.With("url", res.Stream.URL(), "sd_hash", res.Stream.SDHash())
 	if err := c.library.AddRemoteStream(*res.Stream); err != nil {
 		logger.Info("error adding remote stream", "err", err)
@@ -193,3 +296,41 @@ func (c *Conductor) ProcessNextResult() error {
 	logger.Info("remote stream added", "tid", res.Stream.TID())
 	return nil
 }
+
+// processChunk records an encoded chunk and dispatches assembly task once all chunks of the stream are encoded.
+func (c *Conductor) processChunk(cr tasks.ChunkRequest) error {
+	logger := c.options.Logger.With("url", cr.URL, "sd_hash", cr.SDHash, "chunk", cr.Index, "chunks", cr.Count)
+	key := chunksKeyPrefix + cr.SDHash
+	var done *redis.IntCmd
+	_, err := c.rdb.TxPipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		pipe.SAdd(context.Background(), key, cr.Index)
+		pipe.Expire(context.Background(), key, 72*time.Hour)
+		done = pipe.SCard(context.Background(), key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record chunk: %w", err)
+	}
+	logger.Info("chunk encoded", "done", done.Val())
+	if done.Val() < int64(cr.Count) {
+		return nil
+	}
+
+	t, err := tasks.NewAssemblyTask(tasks.AssemblyRequest{TranscodingRequest: cr.TranscodingRequest, Chunks: cr.Count})
+	if err != nil {
+		return fmt.Errorf("task creation error: %w", err)
+	}
+	info, err := c.asynqClient.Enqueue(
+		t,
+		asynq.Unique(24*time.Hour),
+		asynq.Timeout(24*time.Hour),
+		asynq.Retention(72*time.Hour),
+	)
+	if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) {
+		return fmt.Errorf("task enqueue error: %w", err)
+	}
+	if info != nil {
+		logger.Info("enqueued assembly task", "tid", info.ID, "queue", info.Queue)
+	}
+	return c.rdb.Del(context.Background(), key).Err()
+}
@@ -0,0 +1,53 @@
+package conductor
+
+import (
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/ladder"
+	"github.com/OdyseeTeam/transcoder/pkg/conductor/tasks"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitRequest(t *testing.T) {
+	req := tasks.TranscodingRequest{URL: "lbry://video", SDHash: "abc"}
+
+	reqs := splitRequest(req, 3700, 600)
+	assert.Len(t, reqs, 6)
+	for i, r := range reqs {
+		assert.Equal(t, req, r.TranscodingRequest)
+		assert.Equal(t, i, r.Index)
+		assert.Equal(t, 6, r.Count)
+	}
+	assert.InDelta(t, 0, reqs[0].Start, 1e-9)
+	assert.InDelta(t, 3700.0/6, reqs[0].End, 1e-9)
+	assert.InDelta(t, reqs[0].End, reqs[1].Start, 1e-9)
+	assert.InDelta(t, 3700.0*5/6, reqs[5].Start, 1e-9)
+	assert.Zero(t, reqs[5].End)
+
+	reqs = splitRequest(req, 200, 600)
+	assert.Equal(t, []tasks.ChunkRequest{{TranscodingRequest: req, Count: 1}}, reqs)
+}
+
+func TestSplittable(t *testing.T) {
+	av1 := ladder.Default
+	av1.Tiers = append([]ladder.Tier{}, ladder.Default.Tiers...)
+	av1.Tiers[0].Codec = ladder.CodecAV1
+	dash := ladder.Default
+	dash.DASH = true
+	profiles := ladder.NewProfiles()
+	profiles.Add("av1", av1)
+	profiles.Add("dash", dash)
+
+	opts := ConductorOptions{ChunkMinDuration: 3600, ChunkDuration: 600, LadderProfiles: profiles}
+	log := logging.NoopKVLogger{}
+	assert.True(t, opts.splittable(3700, "", log))
+	assert.False(t, opts.splittable(3000, "", log))
+	assert.False(t, opts.splittable(3700, "av1", log))
+	assert.False(t, opts.splittable(3700, "dash", log))
+	assert.False(t, opts.splittable(3700, "unknown", log))
+
+	opts.ChunkMinDuration = 0
+	assert.False(t, opts.splittable(3700, "", log))
+}
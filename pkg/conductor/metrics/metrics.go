@@ -9,6 +9,10 @@ import (
 const (
 	LabelWorkerName   string = "worker_name"
 	LabelStage        string = "stage"
+	LabelErrorKind    string = "kind"
+	LabelTier         string = "tier"
+	LabelCodec        string = "codec"
+	LabelRejectReason string = "reason"
 	StageAccepted     string = "accepted"
 	StageDownloading  string = "downloading"
 	StageEncoding     string = "encoding"
@@ -56,6 +60,30 @@ var (
 	ErrorsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "errors_count",
 	}, []string{LabelStage})
+	EncoderErrorsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "encoder_errors_count",
+		Help: "Number of failed encodes by ffmpeg failure kind",
+	}, []string{LabelErrorKind})
+	InputsRejectedCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "inputs_rejected_count",
+		Help: "Number of inputs rejected by input policy by the violated limit",
+	}, []string{LabelRejectReason})
+
+	RenditionSSIM = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rendition_ssim",
+		Help:    "SSIM of encoded renditions against the source by tier definition",
+		Buckets: []float64{0.8, 0.85, 0.9, 0.92, 0.94, 0.95, 0.96, 0.97, 0.98, 0.99, 1},
+	}, []string{LabelTier, LabelCodec})
+	RenditionPSNR = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rendition_psnr",
+		Help:    "PSNR (dB) of encoded renditions against the source by tier definition",
+		Buckets: []float64{25, 28, 30, 32, 34, 36, 38, 40, 42, 45, 50},
+	}, []string{LabelTier, LabelCodec})
+	RenditionVMAF = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rendition_vmaf",
+		Help:    "VMAF of encoded renditions against the source by tier definition",
+		Buckets: []float64{40, 50, 60, 70, 75, 80, 85, 90, 93, 95, 97, 99},
+	}, []string{LabelTier, LabelCodec})
 
 	DiskUsagePercent = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "transcoder_worker_disk_usage_percent",
@@ -84,7 +112,8 @@ func RegisterWorkerMetrics() {
 			TranscodedSeconds, TranscodedCount,
 			SpentSeconds, StageRunning,
 			InputBytes, OutputBytes,
-			ErrorsCount,
+			ErrorsCount, EncoderErrorsCount, InputsRejectedCount,
+			RenditionSSIM, RenditionPSNR, RenditionVMAF,
 			DiskUsagePercent, DiskWaitTotal, DiskWaitTimeoutTotal,
 		)
 	})
@@ -9,10 +9,30 @@ import (
 type TranscodingRequest struct {
 	URL    string `json:"url"`
 	SDHash string `json:"sd_hash"`
+	// LadderProfile is the name of ladder profile to encode with, worker default is used if empty.
+	LadderProfile string `json:"ladder_profile,omitempty"`
+}
+
+// ChunkRequest is a part of TranscodingRequest covering Start to End seconds of the source video.
+// Workers move both boundaries to the next keyframe, End is zero for the last chunk.
+type ChunkRequest struct {
+	TranscodingRequest
+	Index int     `json:"index"`
+	Count int     `json:"count"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// AssemblyRequest is sent once all chunks of TranscodingRequest are encoded, for stitching and uploading them.
+type AssemblyRequest struct {
+	TranscodingRequest
+	Chunks int `json:"chunks"`
 }
 
 type TranscodingResult struct {
 	Stream *library.Stream `json:"stream"`
+	// Chunk is set instead of Stream when a chunk has been encoded and staged for assembly.
+	Chunk *ChunkRequest `json:"chunk,omitempty"`
 }
 
 func (m TranscodingRequest) String() string {
@@ -24,6 +44,24 @@ func (m *TranscodingRequest) FromString(s string) error {
 	return json.Unmarshal([]byte(s), m)
 }
 
+func (m ChunkRequest) String() string {
+	out, _ := json.Marshal(m)
+	return string(out)
+}
+
+func (m *ChunkRequest) FromString(s string) error {
+	return json.Unmarshal([]byte(s), m)
+}
+
+func (m AssemblyRequest) String() string {
+	out, _ := json.Marshal(m)
+	return string(out)
+}
+
+func (m *AssemblyRequest) FromString(s string) error {
+	return json.Unmarshal([]byte(s), m)
+}
+
 func (m TranscodingResult) String() string {
 	out, _ := json.Marshal(m)
 	return string(out)
@@ -13,6 +13,7 @@ import (
 
 	"github.com/OdyseeTeam/transcoder/encoder"
 	"github.com/OdyseeTeam/transcoder/internal/version"
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/library"
 	"github.com/OdyseeTeam/transcoder/pkg/conductor/diskmon"
 	"github.com/OdyseeTeam/transcoder/pkg/conductor/metrics"
@@ -28,6 +29,10 @@ import (
 
 const (
 	TypeTranscodingRequest = "transcoder:transcode"
+	// TypeChunkRequest encodes a time range of the source video and stages it for assembly.
+	TypeChunkRequest = "transcoder:chunk"
+	// TypeAssemblyRequest stitches staged chunks into a stream and uploads it.
+	TypeAssemblyRequest = "transcoder:assemble"
 
 	QueueTranscodingResults = "transcoding:results"
 )
@@ -48,6 +53,7 @@ type EncoderRunnerOptions struct {
 	Name                  string
 	Logger                logging.KVLogger
 	DiskPressure          diskmon.Config
+	LadderProfiles        *ladder.Profiles
 }
 
 type RedisResultWriter struct {
@@ -85,10 +91,25 @@ func WithDiskPressure(cfg diskmon.Config) func(options *EncoderRunnerOptions) {
 	}
 }
 
+// WithLadderProfiles sets a registry of ladder profiles which tasks can request by name.
+func WithLadderProfiles(profiles *ladder.Profiles) func(options *EncoderRunnerOptions) {
+	return func(options *EncoderRunnerOptions) {
+		options.LadderProfiles = profiles
+	}
+}
+
 func NewTranscodingTask(req TranscodingRequest) (*asynq.Task, error) {
 	return asynq.NewTask(TypeTranscodingRequest, []byte(req.String()), asynq.MaxRetry(5)), nil
 }
 
+func NewChunkTask(req ChunkRequest) (*asynq.Task, error) {
+	return asynq.NewTask(TypeChunkRequest, []byte(req.String()), asynq.MaxRetry(5)), nil
+}
+
+func NewAssemblyTask(req AssemblyRequest) (*asynq.Task, error) {
+	return asynq.NewTask(TypeAssemblyRequest, []byte(req.String()), asynq.MaxRetry(5)), nil
+}
+
 func NewEncoderRunner(
 	storage *storage.S3Driver, encoder encoder.Encoder, resultWriter ResultWriter, optionFuncs ...func(*EncoderRunnerOptions),
 ) (*EncoderRunner, error) {
@@ -133,66 +154,212 @@ func (r *EncoderRunner) Run(ctx context.Context, t *asynq.Task) error {
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
 		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
 	}
+	log := r.taskLogger(t, payload.SDHash)
 
-	var stream *library.Stream
-	log := logging.AddLogRef(r.options.Logger, payload.SDHash)
-	if t.ResultWriter() != nil {
-		log = log.With("tid", t.ResultWriter().TaskID())
+	dl, err := r.retrieve(ctx, payload.URL, log)
+	if err != nil {
+		return err
 	}
+	defer os.RemoveAll(dl.File.Name())
+	return r.encodeWhole(ctx, t, payload, dl, log)
+}
 
-	var origFile, encodedPath string
-	errMtr := metrics.ErrorsCount
+// encodeWhole encodes the downloaded source video in one piece and publishes the stream.
+func (r *EncoderRunner) encodeWhole(
+	ctx context.Context, t *asynq.Task, payload TranscodingRequest, dl *retriever.DownloadResult, log logging.KVLogger,
+) error {
+	encodedPath := path.Join(r.options.OutputDir, dl.Resolved.SDHash)
+	origFile := dl.File.Name()
+	defer os.RemoveAll(encodedPath)
+
+	res, err := r.encode(ctx, log, func() (*encoder.Result, error) {
+		return r.encoderFor(payload, log).EncodeContext(ctx, origFile, encodedPath)
+	})
+	if err != nil {
+		return err
+	}
 
-	var resolved *resolve.ResolvedStream
+	time.Sleep(5 * time.Second)
+	// This is removed twice to not wait for upload to finish before freeing up disk space
+	os.RemoveAll(origFile)
 
-	if r.options.DiskPressure.Enabled {
-		err := diskmon.WaitForDiskSpace(ctx, r.options.DiskPressure, log)
-		if err != nil {
-			log.Error("disk pressure wait failed", "err", err)
-			return fmt.Errorf("disk pressure wait failed: %w", err)
+	return r.publish(t, payload, dl.Resolved, res, encodedPath, log)
+}
+
+// RunChunk encodes a time range of the source video and stages the output in storage
+// until all chunks are done and an assembly task is dispatched.
+// If the requested ladder cannot be encoded in chunks, the first chunk task encodes and publishes
+// the whole video instead and the rest do nothing, so no assembly task is dispatched.
+// A chunk failing for good means the stream will never be assembled, so chunks staged for it are removed.
+func (r *EncoderRunner) RunChunk(ctx context.Context, t *asynq.Task) error {
+	if t.Type() != TypeChunkRequest {
+		return fmt.Errorf("can only handle %s", TypeChunkRequest)
+	}
+	var payload ChunkRequest
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	log := r.taskLogger(t, payload.SDHash).With("chunk", payload.Index, "chunks", payload.Count)
+
+	err := r.runChunk(ctx, t, payload, log)
+	if err != nil && finalFailure(ctx, err) {
+		log.Info("chunk failed for good, removing staged chunks")
+		if err := r.storage.DeleteDir(context.Background(), chunkPrefix(payload.SDHash, -1)); err != nil {
+			log.Warn("failed to remove staged chunks", "err", err)
+		}
+	}
+	return err
+}
+
+func (r *EncoderRunner) runChunk(ctx context.Context, t *asynq.Task, payload ChunkRequest, log logging.KVLogger) error {
+	dl, err := r.retrieve(ctx, payload.URL, log)
+	if err != nil {
+		return err
+	}
+	chunkPath := r.chunkDir(dl.Resolved.SDHash, payload.Index)
+	origFile := dl.File.Name()
+	defer os.RemoveAll(chunkPath)
+	defer os.RemoveAll(origFile)
+
+	_, err = r.encode(ctx, log, func() (*encoder.Result, error) {
+		return r.encoderFor(payload.TranscodingRequest, log).EncodeChunk(ctx, origFile, chunkPath, payload.Start, payload.End)
+	})
+	if errors.Is(err, encoder.ErrChunkingUnsupported) {
+		if payload.Index > 0 {
+			log.Info("ladder cannot be encoded in chunks, leaving the video to the first chunk task")
+			return nil
 		}
+		log.Info("ladder cannot be encoded in chunks, encoding the whole video")
+		return r.encodeWhole(ctx, t, payload.TranscodingRequest, dl, log)
+	}
+	if err != nil {
+		return err
+	}
+	os.RemoveAll(origFile)
+
+	timer := time.Now()
+	runMtr := metrics.StageRunning.WithLabelValues(metrics.StageUploading)
+	spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageUploading)
+	runMtr.Inc()
+	err = r.storage.PutDir(context.Background(), chunkPath, chunkPrefix(payload.SDHash, payload.Index))
+	spentMtr.Add(time.Since(timer).Seconds())
+	runMtr.Dec()
+	if err != nil {
+		metrics.ErrorsCount.WithLabelValues(metrics.StageUploading).Inc()
+		return fmt.Errorf("chunk upload failed: %w", err)
 	}
+	log.Info("chunk staged")
 
-	{
-		timer := time.Now()
-		runMtr := metrics.StageRunning.WithLabelValues(metrics.StageDownloading)
-		spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageDownloading)
+	return r.writeResult(t, TranscodingResult{Chunk: &payload})
+}
 
-		runMtr.Inc()
-		dl, err := retriever.Retrieve(payload.URL, r.options.StreamsDir)
-		if err != nil {
-			log.With("url", payload.URL).Error("download failed", "err", err)
-			errMtr.WithLabelValues(metrics.StageDownloading).Inc()
-			spentMtr.Add(time.Since(timer).Seconds())
-			runMtr.Dec()
-			return fmt.Errorf("download failed: %w", err)
+// RunAssembly fetches staged chunks, stitches them into a single stream and uploads it.
+func (r *EncoderRunner) RunAssembly(ctx context.Context, t *asynq.Task) error {
+	if t.Type() != TypeAssemblyRequest {
+		return fmt.Errorf("can only handle %s", TypeAssemblyRequest)
+	}
+	var payload AssemblyRequest
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+	log := r.taskLogger(t, payload.SDHash).With("chunks", payload.Chunks)
+
+	dl, err := r.retrieve(ctx, payload.URL, log)
+	if err != nil {
+		return err
+	}
+	encodedPath := path.Join(r.options.OutputDir, dl.Resolved.SDHash)
+	origFile := dl.File.Name()
+	defer os.RemoveAll(encodedPath)
+	defer os.RemoveAll(origFile)
+
+	chunkDirs := []string{}
+	timer := time.Now()
+	runMtr := metrics.StageRunning.WithLabelValues(metrics.StageDownloading)
+	spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageDownloading)
+	runMtr.Inc()
+	for i := 0; i < payload.Chunks; i++ {
+		d := r.chunkDir(payload.SDHash, i)
+		defer os.RemoveAll(d)
+		chunkDirs = append(chunkDirs, d)
+		if err = r.storage.GetDir(ctx, chunkPrefix(payload.SDHash, i), d); err != nil {
+			break
 		}
-		metrics.InputBytes.Add(float64(dl.Size))
-		runMtr.Dec()
-		spentMtr.Add(time.Since(timer).Seconds())
-		encodedPath = path.Join(r.options.OutputDir, dl.Resolved.SDHash)
-		origFile = dl.File.Name()
-		defer os.RemoveAll(encodedPath)
-		defer os.RemoveAll(origFile)
-		resolved = dl.Resolved
+	}
+	spentMtr.Add(time.Since(timer).Seconds())
+	runMtr.Dec()
+	if err != nil {
+		metrics.ErrorsCount.WithLabelValues(metrics.StageDownloading).Inc()
+		log.Error("chunks download failed", "err", err)
+		return fmt.Errorf("chunks download failed: %w", err)
 	}
 
-	{
-		timer := time.Now()
-		runMtr := metrics.StageRunning.WithLabelValues(metrics.StageEncoding)
-		spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageEncoding)
+	res, err := r.encode(ctx, log, func() (*encoder.Result, error) {
+		return r.encoderFor(payload.TranscodingRequest, log).Assemble(ctx, origFile, encodedPath, chunkDirs)
+	})
+	if err != nil {
+		return err
+	}
+	os.RemoveAll(origFile)
 
-		runMtr.Inc()
-		res, err := r.encoder.Encode(origFile, encodedPath)
-		if err != nil {
-			log.Error("encoder failure", "err", err)
-			spentMtr.Add(time.Since(timer).Seconds())
-			errMtr.WithLabelValues(metrics.StageEncoding).Inc()
-			runMtr.Dec()
+	if err := r.publish(t, payload.TranscodingRequest, dl.Resolved, res, encodedPath, log); err != nil {
+		return err
+	}
+	if err := r.storage.DeleteDir(context.Background(), chunkPrefix(payload.SDHash, -1)); err != nil {
+		log.Warn("failed to remove staged chunks", "err", err)
+	}
+	return nil
+}
+
+func (r *EncoderRunner) taskLogger(t *asynq.Task, sdHash string) logging.KVLogger {
+	log := logging.AddLogRef(r.options.Logger, sdHash)
+	if t.ResultWriter() != nil {
+		log = log.With("tid", t.ResultWriter().TaskID())
+	}
+	return log
+}
 
-			return fmt.Errorf("encoder failure: %v: %w", err, asynq.SkipRetry)
+// retrieve waits for disk space to become available if configured to do so and downloads the source stream.
+func (r *EncoderRunner) retrieve(ctx context.Context, url string, log logging.KVLogger) (*retriever.DownloadResult, error) {
+	if r.options.DiskPressure.Enabled {
+		err := diskmon.WaitForDiskSpace(ctx, r.options.DiskPressure, log)
+		if err != nil {
+			log.Error("disk pressure wait failed", "err", err)
+			return nil, fmt.Errorf("disk pressure wait failed: %w", err)
 		}
+	}
+
+	timer := time.Now()
+	runMtr := metrics.StageRunning.WithLabelValues(metrics.StageDownloading)
+	spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageDownloading)
+
+	runMtr.Inc()
+	dl, err := retriever.Retrieve(url, r.options.StreamsDir)
+	spentMtr.Add(time.Since(timer).Seconds())
+	runMtr.Dec()
+	if err != nil {
+		log.With("url", url).Error("download failed", "err", err)
+		metrics.ErrorsCount.WithLabelValues(metrics.StageDownloading).Inc()
+		return nil, fmt.Errorf("download failed: %w", err)
+	}
+	metrics.InputBytes.Add(float64(dl.Size))
+	return dl, nil
+}
 
+// encode calls start and waits for the encoding to finish, logging its progress.
+// encoder.ErrChunkingUnsupported is returned as is for the caller to fall back to encoding the whole video.
+func (r *EncoderRunner) encode(ctx context.Context, log logging.KVLogger, start func() (*encoder.Result, error)) (*encoder.Result, error) {
+	timer := time.Now()
+	runMtr := metrics.StageRunning.WithLabelValues(metrics.StageEncoding)
+	spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageEncoding)
+
+	runMtr.Inc()
+	defer func() {
+		spentMtr.Add(time.Since(timer).Seconds())
+		runMtr.Dec()
+	}()
+	res, err := start()
+	if err == nil {
 		seen := map[int]bool{}
 		for p := range res.Progress {
 			pg := int(math.Ceil(p.GetProgress()))
@@ -201,72 +368,183 @@ func (r *EncoderRunner) Run(ctx context.Context, t *asynq.Task) error {
 				log.Info("encoding", "progress", pg)
 			}
 		}
+		err = res.Err()
+	}
+	if err != nil && ctx.Err() != nil {
+		log.Info("encoding cancelled", "err", err)
+		return nil, fmt.Errorf("encoding cancelled: %w", err)
+	}
+	if err != nil {
+		return nil, encodingError(err, log)
+	}
+	return res, nil
+}
 
-		time.Sleep(5 * time.Second)
-		// This is removed twice to not wait for upload to finish before freeing up disk space
-		os.RemoveAll(origFile)
-
-		stream = library.InitStream(encodedPath, r.storage.Name())
-		err = stream.GenerateManifest(
-			payload.URL, resolved.ChannelURI, payload.SDHash,
-			library.WithTranscodedAt(time.Now()),
-			library.WithWorkerName(r.options.Name),
-			library.WithVersion(version.Version),
-			library.WithReleasedAt(resolved.ReleaseTime),
-		)
-		if err != nil {
-			log.Error("failed to fill manifest", "err", err)
-			runMtr.Dec()
-			spentMtr.Add(time.Since(timer).Seconds())
-			errMtr.WithLabelValues(metrics.StageMetadataFill).Inc()
-			return fmt.Errorf("failed to fill manifest: %w", err)
-		}
+// encodingError turns encoder error into task error, marking failures not worth retrying with asynq.SkipRetry.
+// Incomplete inputs are retried since the next attempt downloads them again.
+func encodingError(err error, log logging.KVLogger) error {
+	if errors.Is(err, encoder.ErrChunkingUnsupported) {
+		return err
+	}
+	if errors.Is(err, encoder.ErrIncompleteInput) {
+		log.Warn("input is incomplete", "err", err)
+		metrics.ErrorsCount.WithLabelValues(metrics.StageEncoding).Inc()
+		return fmt.Errorf("encoding failed: %w", err)
+	}
+	var re *encoder.RejectionError
+	if errors.As(err, &re) {
+		log.Warn("input rejected", "reason", re.Reason, "err", err)
+		metrics.InputsRejectedCount.WithLabelValues(string(re.Reason)).Inc()
+		return fmt.Errorf("%v: %w", err, asynq.SkipRetry)
+	}
+	metrics.ErrorsCount.WithLabelValues(metrics.StageEncoding).Inc()
+	return encoderFailure(err, log)
+}
 
-		stream.Manifest.FfmpegArgs = res.Ladder.String()
-		metrics.OutputBytes.Add(float64(stream.Size()))
-		metrics.TranscodedCount.Inc()
-		d, _ := strconv.ParseFloat(res.OrigMeta.FMeta.GetFormat().GetDuration(), 64)
-		metrics.TranscodedSeconds.Add(d)
-		spentMtr.Add(time.Since(timer).Seconds())
-		runMtr.Dec()
+// publish generates manifest for the encoded stream, uploads it and reports the result.
+func (r *EncoderRunner) publish(
+	t *asynq.Task, payload TranscodingRequest, resolved *resolve.ResolvedStream, res *encoder.Result,
+	encodedPath string, log logging.KVLogger,
+) error {
+	manifestFuncs := []func(*library.Manifest){
+		library.WithTranscodedAt(time.Now()),
+		library.WithWorkerName(r.options.Name),
+		library.WithVersion(version.Version),
+		library.WithReleasedAt(resolved.ReleaseTime),
+		library.WithCodecs(res.Ladder.CodecNames()),
+		library.WithAudioOnly(res.Ladder.IsAudioOnly()),
+	}
+	if c := res.OrigMeta.Complexity; c != nil {
+		manifestFuncs = append(manifestFuncs, library.WithComplexity(c.SampleBitrate, c.Factor))
+	}
+	if len(res.Quality) > 0 {
+		manifestFuncs = append(manifestFuncs, library.WithQuality(renditionQuality(res.Quality)))
+	}
 
-		log.Info("encoding done", "stream_size", stream.Size())
-		defer os.RemoveAll(stream.LocalPath)
+	stream := library.InitStream(encodedPath, r.storage.Name())
+	err := stream.GenerateManifest(payload.URL, resolved.ChannelURI, payload.SDHash, manifestFuncs...)
+	if err != nil {
+		log.Error("failed to fill manifest", "err", err)
+		metrics.ErrorsCount.WithLabelValues(metrics.StageMetadataFill).Inc()
+		return fmt.Errorf("failed to fill manifest: %w", err)
 	}
 
-	{
-		timer := time.Now()
-		runMtr := metrics.StageRunning.WithLabelValues(metrics.StageUploading)
-		spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageUploading)
+	stream.Manifest.FfmpegArgs = res.Ladder.String()
+	metrics.OutputBytes.Add(float64(stream.Size()))
+	metrics.TranscodedCount.Inc()
+	d, _ := strconv.ParseFloat(res.OrigMeta.FMeta.GetFormat().GetDuration(), 64)
+	metrics.TranscodedSeconds.Add(d)
 
-		runMtr.Inc()
-		err := r.storage.PutWithContext(context.Background(), stream, true)
-		if err != nil {
-			errMtr.WithLabelValues(metrics.StageUploading).Inc()
-			spentMtr.Add(time.Since(timer).Seconds())
-			runMtr.Dec()
-			if errors.Is(err, storage.ErrStreamExists) {
-				return fmt.Errorf("stream already exists: %v: %w", err, asynq.SkipRetry)
-			}
-			return fmt.Errorf("stream upload failed: %w", err)
-		}
-		log.Info("stream uploaded")
-		spentMtr.Add(time.Since(timer).Seconds())
-		runMtr.Dec()
-		res, err := json.Marshal(TranscodingResult{Stream: stream})
-		if err != nil {
-			return fmt.Errorf("cannot serialize transcoding result: %w", err)
+	log.Info("encoding done", "stream_size", stream.Size())
+	defer os.RemoveAll(stream.LocalPath)
+
+	timer := time.Now()
+	runMtr := metrics.StageRunning.WithLabelValues(metrics.StageUploading)
+	spentMtr := metrics.SpentSeconds.WithLabelValues(metrics.StageUploading)
+
+	runMtr.Inc()
+	err = r.storage.PutWithContext(context.Background(), stream, true)
+	spentMtr.Add(time.Since(timer).Seconds())
+	runMtr.Dec()
+	if err != nil {
+		metrics.ErrorsCount.WithLabelValues(metrics.StageUploading).Inc()
+		if errors.Is(err, storage.ErrStreamExists) {
+			return fmt.Errorf("stream already exists: %v: %w", err, asynq.SkipRetry)
 		}
-		if t.ResultWriter() != nil {
-			t.ResultWriter().Write(res)
+		return fmt.Errorf("stream upload failed: %w", err)
+	}
+	log.Info("stream uploaded")
+
+	if err := r.writeResult(t, TranscodingResult{Stream: stream}); err != nil {
+		return err
+	}
+	log.Info("stream processed")
+	return nil
+}
+
+// renditionQuality converts encoder quality scores for the manifest and records them in metrics.
+func renditionQuality(qs []encoder.Quality) []library.Quality {
+	lqs := []library.Quality{}
+	for _, q := range qs {
+		def := string(q.Definition)
+		metrics.RenditionSSIM.WithLabelValues(def, q.Codec).Observe(q.SSIM)
+		metrics.RenditionPSNR.WithLabelValues(def, q.Codec).Observe(q.PSNR)
+		if q.VMAF > 0 {
+			metrics.RenditionVMAF.WithLabelValues(def, q.Codec).Observe(q.VMAF)
 		}
-		r.resultWriter.Write(res)
-		log.Info("stream processed")
+		lqs = append(lqs, library.Quality{Definition: def, Codec: q.Codec, SSIM: q.SSIM, PSNR: q.PSNR, VMAF: q.VMAF})
 	}
+	return lqs
+}
 
+func (r *EncoderRunner) writeResult(t *asynq.Task, result TranscodingResult) error {
+	res, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("cannot serialize transcoding result: %w", err)
+	}
+	if t.ResultWriter() != nil {
+		t.ResultWriter().Write(res)
+	}
+	r.resultWriter.Write(res)
 	return nil
 }
 
+// chunkDir is a local directory for chunk number n of the stream.
+func (r *EncoderRunner) chunkDir(sdHash string, n int) string {
+	return path.Join(r.options.OutputDir, fmt.Sprintf("%s_chunk%03d", sdHash, n))
+}
+
+// chunkPrefix is the storage prefix chunk number n of the stream is staged under until assembled,
+// negative n gives the prefix for all chunks of the stream.
+func chunkPrefix(sdHash string, n int) string {
+	if n < 0 {
+		return "chunks/" + sdHash
+	}
+	return fmt.Sprintf("chunks/%s/%03d", sdHash, n)
+}
+
+// finalFailure tells if the task failed with err is not going to be retried.
+func finalFailure(ctx context.Context, err error) bool {
+	if errors.Is(err, asynq.SkipRetry) {
+		return true
+	}
+	n, ok := asynq.GetRetryCount(ctx)
+	maxRetry, maxOk := asynq.GetMaxRetry(ctx)
+	return ok && maxOk && n >= maxRetry
+}
+
+// encoderFailure logs and counts encoder error. Failures are not retried
+// unless ffmpeg failed for a reason which might not repeat, like running out of disk space.
+func encoderFailure(err error, log logging.KVLogger) error {
+	kind := encoder.ErrorUnknown
+	var fe *encoder.FfmpegError
+	if errors.As(err, &fe) {
+		kind = fe.Kind
+	}
+	log.Error("encoder failure", "err", err, "kind", kind)
+	metrics.EncoderErrorsCount.WithLabelValues(string(kind)).Inc()
+	if fe != nil && fe.Transient() {
+		return fmt.Errorf("encoder failure: %w", err)
+	}
+	return fmt.Errorf("encoder failure: %v: %w", err, asynq.SkipRetry)
+}
+
+// encoderFor returns encoder configured with the ladder profile requested in the payload.
+// Unknown profiles fall back to the encoder default ladder.
+func (r *EncoderRunner) encoderFor(payload TranscodingRequest, log logging.KVLogger) encoder.Encoder {
+	if payload.LadderProfile == "" {
+		return r.encoder
+	}
+	if r.options.LadderProfiles != nil {
+		if l, ok := r.options.LadderProfiles.Get(payload.LadderProfile); ok {
+			log.Info("using ladder profile", "profile", payload.LadderProfile)
+			return r.encoder.WithLadder(l)
+		}
+	}
+	log.Warn("ladder profile not found, using default", "profile", payload.LadderProfile)
+	return r.encoder
+}
+
 func (r *EncoderRunner) RetryDelay(n int, e error, t *asynq.Task) time.Duration {
 	if errors.Is(e, resolve.ErrNotReflected) {
 		delay := 1 * time.Hour
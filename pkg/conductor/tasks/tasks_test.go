@@ -0,0 +1,47 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/OdyseeTeam/transcoder/encoder"
+	"github.com/OdyseeTeam/transcoder/pkg/logging"
+	"github.com/OdyseeTeam/transcoder/pkg/mp4box"
+
+	"github.com/hibiken/asynq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingError(t *testing.T) {
+	truncated := &mp4box.TruncatedError{Box: mp4box.Box{Type: "mdat", Offset: 124, Size: 1000}, FileSize: 624}
+	testCases := []struct {
+		name  string
+		err   error
+		retry bool
+	}{
+		{"incomplete input", fmt.Errorf("container check failed: %w", fmt.Errorf("%w: %w", encoder.ErrIncompleteInput, truncated)), true},
+		{"no moov", fmt.Errorf("container check failed: %w", &encoder.RejectionError{Reason: encoder.RejectContainer}), false},
+		{"out of disk", &encoder.FfmpegError{Kind: encoder.ErrorOutOfDisk}, true},
+		{"corrupt input", &encoder.FfmpegError{Kind: encoder.ErrorCorruptInput}, false},
+		{"unknown", errors.New("ffmpeg exited"), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := encodingError(tc.err, logging.NoopKVLogger{})
+			assert.Equal(t, !tc.retry, errors.Is(err, asynq.SkipRetry), err)
+		})
+	}
+
+	err := encodingError(fmt.Errorf("prepare: %w", encoder.ErrChunkingUnsupported), logging.NoopKVLogger{})
+	assert.ErrorIs(t, err, encoder.ErrChunkingUnsupported)
+	assert.NotErrorIs(t, err, asynq.SkipRetry)
+}
+
+func TestFinalFailure(t *testing.T) {
+	assert.True(t, finalFailure(context.Background(), fmt.Errorf("input rejected: %w", asynq.SkipRetry)))
+	assert.False(t, finalFailure(context.Background(), errors.New("download failed")))
+	assert.Equal(t, "chunks/abc", chunkPrefix("abc", -1))
+	assert.Equal(t, "chunks/abc/002", chunkPrefix("abc", 2))
+}
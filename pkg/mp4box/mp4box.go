@@ -0,0 +1,181 @@
+// Package mp4box reads the top-level box structure of ISO-BMFF (MP4, MOV) files without parsing box contents.
+package mp4box
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	headerSize      = 8
+	largeHeaderSize = 16
+)
+
+var (
+	// ErrNotISOBMFF is returned for files which do not start with an ISO-BMFF box.
+	ErrNotISOBMFF = errors.New("not an ISO-BMFF file")
+	// ErrNoMoov is returned for files which have no movie box and so cannot be played back.
+	ErrNoMoov = errors.New("moov box missing")
+)
+
+// firstBoxTypes are box types an ISO-BMFF or QuickTime file can start with.
+var firstBoxTypes = map[string]bool{
+	"ftyp": true, "styp": true, "moov": true, "mdat": true,
+	"free": true, "skip": true, "wide": true, "pnot": true, "uuid": true,
+}
+
+// Box is a top-level box of the file.
+type Box struct {
+	Type   string
+	Offset int64
+	// Size includes the box header.
+	Size int64
+}
+
+func (b Box) end() int64 {
+	return b.Offset + b.Size
+}
+
+// Layout describes top-level boxes of the file.
+type Layout struct {
+	Boxes []Box
+	// MoovOffset is the offset of the movie box, -1 if there is none.
+	MoovOffset int64
+	// Fragmented is set for files containing movie fragments.
+	Fragmented bool
+}
+
+// TruncatedError is returned when a box extends past the end of the file, usually because the file
+// was not uploaded or downloaded completely.
+type TruncatedError struct {
+	Box      Box
+	FileSize int64
+}
+
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("%s box at %d is truncated: %d bytes declared, %d present",
+		e.Box.Type, e.Box.Offset, e.Box.Size, e.FileSize-e.Box.Offset)
+}
+
+// FastStart tells if the movie box precedes media data, allowing playback to start before the whole file is loaded.
+func (l Layout) FastStart() bool {
+	if l.MoovOffset < 0 {
+		return false
+	}
+	for _, b := range l.Boxes {
+		if b.Type == "mdat" {
+			return l.MoovOffset < b.Offset
+		}
+	}
+	return true
+}
+
+// Order returns types of top-level boxes in the order they appear in the file.
+func (l Layout) Order() []string {
+	types := make([]string, len(l.Boxes))
+	for i, b := range l.Boxes {
+		types[i] = b.Type
+	}
+	return types
+}
+
+// ScanFile reads top-level box structure of the named file.
+func ScanFile(name string) (*Layout, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Scan(f, fi.Size())
+}
+
+// Scan reads top-level box structure of a file of the given size. Only box headers are read.
+// ErrNotISOBMFF is returned if the file does not start with a box, *TruncatedError if the last box
+// is cut short and ErrNoMoov if the file has no movie box.
+func Scan(r io.ReaderAt, size int64) (*Layout, error) {
+	l := &Layout{MoovOffset: -1}
+	for off := int64(0); off < size; {
+		b, err := readBox(r, off, size)
+		if err != nil {
+			if len(l.Boxes) == 0 {
+				return nil, ErrNotISOBMFF
+			}
+			return l, err
+		}
+		if len(l.Boxes) == 0 && !firstBoxTypes[b.Type] {
+			return nil, ErrNotISOBMFF
+		}
+		l.Boxes = append(l.Boxes, b)
+		switch b.Type {
+		case "moov":
+			if l.MoovOffset < 0 {
+				l.MoovOffset = b.Offset
+			}
+		case "moof":
+			l.Fragmented = true
+		}
+		if b.end() > size {
+			return l, &TruncatedError{Box: b, FileSize: size}
+		}
+		off = b.end()
+	}
+	if len(l.Boxes) == 0 {
+		return nil, ErrNotISOBMFF
+	}
+	if l.MoovOffset < 0 {
+		return l, ErrNoMoov
+	}
+	return l, nil
+}
+
+// readBox reads box header at off. Zero size means the box extends to the end of the file.
+func readBox(r io.ReaderAt, off, fileSize int64) (Box, error) {
+	b := Box{Offset: off}
+	h := make([]byte, largeHeaderSize)
+	n, err := r.ReadAt(h, off)
+	if n < headerSize {
+		if err == nil || errors.Is(err, io.EOF) {
+			err = fmt.Errorf("box header at %d is truncated", off)
+		}
+		return b, err
+	}
+	b.Type = string(h[4:8])
+	if !validType(b.Type) {
+		return b, fmt.Errorf("invalid box type %q at %d", b.Type, off)
+	}
+	b.Size = int64(binary.BigEndian.Uint32(h[0:4]))
+	switch b.Size {
+	case 0:
+		b.Size = fileSize - off
+	case 1:
+		if n < largeHeaderSize {
+			return b, fmt.Errorf("%s box header at %d is truncated", b.Type, off)
+		}
+		b.Size = int64(binary.BigEndian.Uint64(h[8:16])) // #nosec G115
+		if b.Size < largeHeaderSize {
+			return b, fmt.Errorf("invalid %s box size %d at %d", b.Type, b.Size, off)
+		}
+	default:
+		if b.Size < headerSize {
+			return b, fmt.Errorf("invalid %s box size %d at %d", b.Type, b.Size, off)
+		}
+	}
+	return b, nil
+}
+
+// validType checks that box type consists of printable ASCII characters, as all registered types do.
+func validType(t string) bool {
+	for i := 0; i < len(t); i++ {
+		if t[i] < 0x20 || t[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package mp4box
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// box builds a box of the given type with payload of n zero bytes.
+func box(typ string, n int) []byte {
+	b := make([]byte, headerSize+n)
+	binary.BigEndian.PutUint32(b, uint32(headerSize+n)) // #nosec G115
+	copy(b[4:8], typ)
+	return b
+}
+
+// largeBox builds a box with 64-bit size header.
+func largeBox(typ string, n int) []byte {
+	b := make([]byte, largeHeaderSize+n)
+	binary.BigEndian.PutUint32(b, 1)
+	copy(b[4:8], typ)
+	binary.BigEndian.PutUint64(b[8:], uint64(largeHeaderSize+n)) // #nosec G115
+	return b
+}
+
+func scan(boxes ...[]byte) (*Layout, error) {
+	data := bytes.Join(boxes, nil)
+	return Scan(bytes.NewReader(data), int64(len(data)))
+}
+
+func TestScan(t *testing.T) {
+	l, err := scan(box("ftyp", 16), box("moov", 100), box("mdat", 1000))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ftyp", "moov", "mdat"}, l.Order())
+	assert.Equal(t, int64(24), l.MoovOffset)
+	assert.Equal(t, Box{Type: "mdat", Offset: 132, Size: 1008}, l.Boxes[2])
+	assert.True(t, l.FastStart())
+	assert.False(t, l.Fragmented)
+
+	l, err = scan(box("ftyp", 16), box("free", 0), box("mdat", 1000), box("moov", 100))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ftyp", "free", "mdat", "moov"}, l.Order())
+	assert.Equal(t, int64(1040), l.MoovOffset)
+	assert.False(t, l.FastStart())
+
+	l, err = scan(box("ftyp", 16), box("moov", 100), box("moof", 20), box("mdat", 100), box("moof", 20), box("mdat", 100))
+	require.NoError(t, err)
+	assert.True(t, l.Fragmented)
+	assert.True(t, l.FastStart())
+}
+
+func TestScanSizes(t *testing.T) {
+	l, err := scan(box("ftyp", 16), box("moov", 100), largeBox("mdat", 1000))
+	require.NoError(t, err)
+	assert.Equal(t, Box{Type: "mdat", Offset: 132, Size: 1016}, l.Boxes[2])
+
+	last := box("mdat", 1000)
+	binary.BigEndian.PutUint32(last, 0)
+	l, err = scan(box("ftyp", 16), box("moov", 100), last)
+	require.NoError(t, err)
+	assert.Equal(t, Box{Type: "mdat", Offset: 132, Size: 1008}, l.Boxes[2])
+}
+
+func TestScanErrors(t *testing.T) {
+	mdat := box("mdat", 1000)
+	l, err := scan(box("ftyp", 16), box("moov", 100), mdat[:500])
+	var te *TruncatedError
+	require.ErrorAs(t, err, &te)
+	assert.Equal(t, "mdat", te.Box.Type)
+	assert.EqualError(t, err, "mdat box at 132 is truncated: 1008 bytes declared, 500 present")
+	require.NotNil(t, l)
+	assert.True(t, l.FastStart())
+
+	_, err = scan(box("ftyp", 16), box("mdat", 1000))
+	assert.ErrorIs(t, err, ErrNoMoov)
+
+	l, err = scan(box("ftyp", 16), box("moov", 100), box("mdat", 10), []byte{0, 0, 0})
+	assert.ErrorContains(t, err, "box header at 150 is truncated")
+	assert.Equal(t, []string{"ftyp", "moov", "mdat"}, l.Order())
+
+	l, err = scan(box("ftyp", 16), box("moov", 100), []byte{0, 0, 0, 4, 'm', 'd', 'a', 't'})
+	assert.ErrorContains(t, err, "invalid mdat box size 4 at 132")
+	assert.Len(t, l.Boxes, 2)
+
+	for _, data := range [][]byte{
+		{0x1a, 0x45, 0xdf, 0xa3, 0x9f, 0x42, 0x86, 0x81, 0x01}, // matroska
+		[]byte("stub"),
+		{},
+		box("abcd", 10),
+	} {
+		l, err := Scan(bytes.NewReader(data), int64(len(data)))
+		assert.ErrorIs(t, err, ErrNotISOBMFF)
+		assert.Nil(t, l)
+	}
+}
+
+func TestScanFile(t *testing.T) {
+	name := path.Join(t.TempDir(), "in.mp4")
+	require.NoError(t, os.WriteFile(name, bytes.Join([][]byte{box("ftyp", 16), box("mdat", 1000), box("moov", 100)}, nil), 0600))
+	l, err := ScanFile(name)
+	require.NoError(t, err)
+	assert.False(t, l.FastStart())
+
+	_, err = ScanFile(path.Join(t.TempDir(), "missing.mp4"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
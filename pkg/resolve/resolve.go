@@ -47,6 +47,8 @@ type ResolvedStream struct {
 	ChannelClaimID, NormalizedName string
 	ChannelSupportAmount int64
 	ReleaseTime          time.Time
+	// Duration is the video duration in seconds as declared in the claim, zero if unknown.
+	Duration int
 }
 
 func (wc *WriteCounter) Write(p []byte) (int, error) {
@@ -104,6 +106,7 @@ func ResolveStream(uri string) (*ResolvedStream, error) {
 		ChannelClaimID:       claim.SigningChannel.ClaimID,
 		ChannelSupportAmount: int64(math.Floor(sup)),
 		ReleaseTime:          releaseTime,
+		Duration:             int(stream.GetVideo().GetDuration()),
 	}
 	return r, nil
 }
@@ -117,19 +117,14 @@ func (s *S3Driver) PutWithContext(ctx context.Context, stream *library.Stream, o
 	ul := manager.NewUploader(s.client)
 	err := stream.Walk(
 		func(fi fs.FileInfo, fullPath, name string) error {
-			var ctype string
 			f, err := os.Open(fullPath)
 			if err != nil {
 				return err
 			}
 			defer f.Close()
 
-			switch path.Ext(name) {
-			case library.PlaylistExt:
-				ctype = library.PlaylistContentType
-			case library.FragmentExt:
-				ctype = library.FragmentContentType
-			default:
+			ctype := library.ContentType(name)
+			if ctype == "" {
 				ctype = "text/plain"
 			}
 			logger.Debugw("uploading", "key", s3FileKey(stream.TID(), name), "ctype", ctype, "size", fi.Size(), "bucket", s.config.Bucket)
@@ -150,6 +145,112 @@ func (s *S3Driver) PutWithContext(ctx context.Context, stream *library.Stream, o
 	return err
 }
 
+// PutDir uploads files from a local directory under prefix. It is meant for staging intermediate
+// encoding results between workers, so uploaded objects are not made public.
+func (s *S3Driver) PutDir(ctx context.Context, dir, prefix string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	ul := manager.NewUploader(s.client)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if err := s.putFile(ctx, ul, path.Join(dir, e.Name()), s3FileKey(prefix, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *S3Driver) putFile(ctx context.Context, ul *manager.Uploader, name, key string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	logger.Debugw("uploading", "key", key, "bucket", s.config.Bucket)
+	_, err = ul.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(key),
+		Body:   f,
+	})
+	return err
+}
+
+// GetDir downloads all objects stored under prefix into a local directory.
+func (s *S3Driver) GetDir(ctx context.Context, prefix, dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+	dl := manager.NewDownloader(s.client)
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.config.Bucket),
+		Prefix: aws.String(prefix + "/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, o := range page.Contents {
+			name := path.Base(aws.ToString(o.Key))
+			if err := s.getFile(ctx, dl, aws.ToString(o.Key), path.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteDir removes all objects stored under prefix, going through as many listing pages as needed.
+func (s *S3Driver) DeleteDir(ctx context.Context, prefix string) error {
+	bucket := aws.String(s.config.Bucket)
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: bucket,
+		Prefix: aws.String(prefix + "/"),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, o := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: o.Key})
+		}
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: bucket,
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			e := out.Errors[0]
+			return fmt.Errorf("failed to delete %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+		}
+	}
+	return nil
+}
+
+func (s *S3Driver) getFile(ctx context.Context, dl *manager.Downloader, key, name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = dl.Download(ctx, f, &s3.GetObjectInput{
+		Bucket: aws.String(s.config.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
 func (s *S3Driver) Delete(tid string) error {
 	ctx, cancelFn := context.WithTimeout(context.Background(), 600*time.Second)
 	bucket := aws.String(s.config.Bucket)
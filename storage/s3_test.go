@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
 	"testing"
 
@@ -11,6 +12,8 @@ import (
 	"github.com/OdyseeTeam/transcoder/library"
 
 	randomdata "github.com/Pallinder/go-randomdata"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/docker/go-connections/nat"
 	"github.com/stretchr/testify/suite"
@@ -114,6 +117,32 @@ func (s *s3suite) TestDeleteFragments() {
 	}
 }
 
+func (s *s3suite) TestDeleteDir() {
+	ctx := context.Background()
+	s3drv := s.s3driver
+	prefix := "chunks/" + randomdata.Alphanumeric(96)
+
+	// More objects than a single listing page holds.
+	dir := s.T().TempDir()
+	for i := 0; i < 1100; i++ {
+		s.Require().NoError(os.WriteFile(path.Join(dir, fmt.Sprintf("v0_s%06d.ts", i)), []byte("segment"), 0600))
+	}
+	s.Require().NoError(s3drv.PutDir(ctx, dir, prefix+"/000"))
+	other := s.T().TempDir()
+	s.Require().NoError(os.WriteFile(path.Join(other, "v0.m3u8"), []byte("playlist"), 0600))
+	s.Require().NoError(s3drv.PutDir(ctx, other, prefix+"0/000"))
+
+	s.Require().NoError(s3drv.DeleteDir(ctx, prefix))
+
+	out, err := s3drv.client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s3drv.config.Bucket),
+		Prefix: aws.String(prefix),
+	})
+	s.Require().NoError(err)
+	s.Require().Len(out.Contents, 1, "objects of other prefixes should be left alone")
+	s.Equal(prefix+"0/000/v0.m3u8", aws.ToString(out.Contents[0].Key))
+}
+
 func (s *s3suite) putStream() *library.Stream {
 	streamsPath := s.T().TempDir()
 	sdHash := randomdata.Alphanumeric(96)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/OdyseeTeam/transcoder/client"
 	"github.com/OdyseeTeam/transcoder/encoder"
+	"github.com/OdyseeTeam/transcoder/ladder"
 	"github.com/OdyseeTeam/transcoder/library"
 	ldb "github.com/OdyseeTeam/transcoder/library/db"
 	"github.com/OdyseeTeam/transcoder/pkg/logging"
@@ -49,11 +51,18 @@ var CLI struct {
 		URL  string `arg:"" help:"Stream URL"`
 	} `cmd:"" help:"Generate stream"`
 	Transcode struct {
-		URL string `arg:"" help:"LBRY URL"`
+		URL         string `arg:"" help:"LBRY URL"`
+		Profile     string `optional:"" help:"Ladder profile name"`
+		ProfilesDir string `optional:"" help:"Directory containing ladder profiles" default:"./ladder/profiles"`
 	} `cmd:"" help:"Download and transcode a specified video"`
 	ValidateStream struct {
 		URL string `arg:"" help:"HTTP URL for stream to verify"`
 	} `cmd:"" help:"Verify a specified stream"`
+	Ladder struct {
+		Lint struct {
+			Files []string `arg:"" type:"existingfile" help:"Ladder YAML files to check"`
+		} `cmd:"" help:"Check ladder definitions for errors"`
+	} `cmd:"" help:"Ladder tools"`
 }
 
 func main() {
@@ -97,10 +106,21 @@ func main() {
 			defer os.RemoveAll(tmpDir)
 		}
 
-		e, err := encoder.NewEncoder(encoder.Configure().Log(log).SpritegenPath("/dev/null"))
+		e, err := encoder.NewEncoder(encoder.Configure().Log(log).Sprites(nil))
 		if err != nil {
 			panic(err)
 		}
+		if CLI.Transcode.Profile != "" {
+			profiles, err := ladder.LoadProfiles(CLI.Transcode.ProfilesDir)
+			if err != nil {
+				panic(err)
+			}
+			l, ok := profiles.Get(CLI.Transcode.Profile)
+			if !ok {
+				panic(fmt.Sprintf("ladder profile %s not found, available: %v", CLI.Transcode.Profile, profiles.Names()))
+			}
+			e = e.WithLadder(l)
+		}
 		t := time.Now()
 		r, err := e.Encode(inPath, outPath)
 		if err != nil {
@@ -194,6 +214,31 @@ func main() {
 			fmt.Fprintln(os.Stderr, "reading standard input:", err)
 		}
 		wg.Wait()
+	case "ladder lint <files>":
+		failed := false
+		for _, f := range CLI.Ladder.Lint.Files {
+			d, err := os.ReadFile(f)
+			if err != nil {
+				panic(err)
+			}
+			err = ladder.Validate(d)
+			var verrs ladder.ValidationErrors
+			switch {
+			case err == nil:
+				fmt.Printf("%s: ok\n", f)
+				continue
+			case errors.As(err, &verrs):
+				for _, e := range verrs {
+					fmt.Printf("%s: %s\n", f, e)
+				}
+			default:
+				fmt.Printf("%s: %s\n", f, err)
+			}
+			failed = true
+		}
+		if failed {
+			os.Exit(1)
+		}
 	default:
 		panic(ctx.Command())
 	}